@@ -0,0 +1,61 @@
+package jtree
+
+import (
+	"math/big"
+)
+
+// AsString returns the value of the string node
+func AsString(n Node) (string, bool) {
+	s, ok := n.(String)
+	return string(s), ok
+}
+
+// AsInt64 returns the value of the number node if it's an integer fitting into int64
+func AsInt64(n Node) (int64, bool) {
+	if num, ok := n.(*Num); ok {
+		return num.Int64()
+	}
+	return 0, false
+}
+
+// AsUint64 returns the value of the number node if it's a non-negative integer fitting into uint64
+func AsUint64(n Node) (uint64, bool) {
+	num, ok := n.(*Num)
+	if !ok || !num.IsInt() {
+		return 0, false
+	}
+	v, acc := (*big.Float)(num).Uint64()
+	return v, acc == big.Exact
+}
+
+// AsFloat64 returns the nearest float64 value of the number node if it's within float64 range
+func AsFloat64(n Node) (float64, bool) {
+	if num, ok := n.(*Num); ok {
+		return num.Float64()
+	}
+	return 0, false
+}
+
+// AsBool returns the value of the boolean node
+func AsBool(n Node) (bool, bool) {
+	b, ok := n.(Bool)
+	return bool(b), ok
+}
+
+// AsObject returns the object node
+func AsObject(n Node) (Object, bool) {
+	o, ok := n.(Object)
+	return o, ok
+}
+
+// AsArray returns the array node
+func AsArray(n Node) (Array, bool) {
+	a, ok := n.(Array)
+	return a, ok
+}
+
+// IsNull returns true if n is the null node
+func IsNull(n Node) bool {
+	_, ok := n.(Null)
+	return ok
+}
@@ -0,0 +1,51 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessors(t *testing.T) {
+	src := mustParse(t, `{"s": "x", "i": -3, "f": 1.5, "big": 1e30, "b": true, "a": [], "n": null}`).(jtree.Object)
+	field := src.FieldByName
+
+	s, ok := jtree.AsString(field("s"))
+	assert.True(t, ok)
+	assert.Equal(t, "x", s)
+	_, ok = jtree.AsString(field("i"))
+	assert.False(t, ok)
+
+	i, ok := jtree.AsInt64(field("i"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(-3), i)
+	_, ok = jtree.AsInt64(field("f"))
+	assert.False(t, ok)
+	_, ok = jtree.AsInt64(field("big"))
+	assert.False(t, ok)
+	_, ok = jtree.AsUint64(field("i"))
+	assert.False(t, ok)
+
+	f, ok := jtree.AsFloat64(field("f"))
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, f)
+	_, ok = jtree.AsFloat64(field("s"))
+	assert.False(t, ok)
+
+	b, ok := jtree.AsBool(field("b"))
+	assert.True(t, ok && b)
+
+	o, ok := jtree.AsObject(src)
+	assert.True(t, ok)
+	assert.Equal(t, src, o)
+	_, ok = jtree.AsObject(field("a"))
+	assert.False(t, ok)
+	_, ok = jtree.AsArray(field("a"))
+	assert.True(t, ok)
+	_, ok = jtree.AsArray(nil)
+	assert.False(t, ok)
+
+	assert.True(t, jtree.IsNull(field("n")))
+	assert.False(t, jtree.IsNull(field("missing")))
+}
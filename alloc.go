@@ -0,0 +1,84 @@
+package jtree
+
+import "sync"
+
+// NodeAllocator is used by Parser to create nodes. It allows to supply arena, pooling or tracking allocation strategies
+type NodeAllocator interface {
+	// NewNum returns the zero number node to be initialized by the parser
+	NewNum() *Num
+	// NewString returns the string node holding s. The allocator may return an interned copy
+	NewString(s string) String
+	// NewField returns the object member
+	NewField(key string, value Node) *Field
+}
+
+type heapAllocator struct{}
+
+func (heapAllocator) NewNum() *Num                           { return new(Num) }
+func (heapAllocator) NewString(s string) String              { return String(s) }
+func (heapAllocator) NewField(key string, value Node) *Field { return &Field{Key: key, Value: value} }
+
+// HeapAllocator is the default allocator which creates nodes on the heap
+var HeapAllocator NodeAllocator = heapAllocator{}
+
+// OpAllocator sets the node allocator used by the parser
+func OpAllocator(a NodeAllocator) ParserOption { return func(o *parserOptions) { o.alloc = a } }
+
+const arenaChunk = 256
+
+var (
+	numChunks   = sync.Pool{New: func() interface{} { return new([arenaChunk]Num) }}
+	fieldChunks = sync.Pool{New: func() interface{} { return new([arenaChunk]Field) }}
+)
+
+// Arena is the allocator creating number nodes and object members in pooled chunks. After Release all nodes
+// created by the arena become invalid and must not be used. Arena is not safe for concurrent use
+type Arena struct {
+	nums   []*[arenaChunk]Num
+	fields []*[arenaChunk]Field
+	numN   int
+	fieldN int
+}
+
+// NewArena returns new empty Arena
+func NewArena() *Arena { return &Arena{numN: arenaChunk, fieldN: arenaChunk} }
+
+// NewNum returns the zero number node
+func (a *Arena) NewNum() *Num {
+	if a.numN == arenaChunk {
+		a.nums = append(a.nums, numChunks.Get().(*[arenaChunk]Num))
+		a.numN = 0
+	}
+	n := &a.nums[len(a.nums)-1][a.numN]
+	a.numN++
+	return n
+}
+
+// NewString returns s as is
+func (a *Arena) NewString(s string) String { return String(s) }
+
+// NewField returns the object member
+func (a *Arena) NewField(key string, value Node) *Field {
+	if a.fieldN == arenaChunk {
+		a.fields = append(a.fields, fieldChunks.Get().(*[arenaChunk]Field))
+		a.fieldN = 0
+	}
+	f := &a.fields[len(a.fields)-1][a.fieldN]
+	a.fieldN++
+	f.Key, f.Value = key, value
+	return f
+}
+
+// Release returns the memory to the pool. The arena can be reused afterwards
+func (a *Arena) Release() {
+	for _, c := range a.nums {
+		*c = [arenaChunk]Num{}
+		numChunks.Put(c)
+	}
+	for _, c := range a.fields {
+		*c = [arenaChunk]Field{}
+		fieldChunks.Put(c)
+	}
+	a.nums, a.fields = a.nums[:0], a.fields[:0]
+	a.numN, a.fieldN = arenaChunk, arenaChunk
+}
@@ -0,0 +1,74 @@
+package jtree_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingAllocator struct {
+	nums, strings, fields int
+	interned              map[string]jtree.String
+}
+
+func (a *countingAllocator) NewNum() *jtree.Num {
+	a.nums++
+	return jtree.HeapAllocator.NewNum()
+}
+
+func (a *countingAllocator) NewString(s string) jtree.String {
+	a.strings++
+	if v, ok := a.interned[s]; ok {
+		return v
+	}
+	a.interned[s] = jtree.String(s)
+	return a.interned[s]
+}
+
+func (a *countingAllocator) NewField(key string, value jtree.Node) *jtree.Field {
+	a.fields++
+	return jtree.HeapAllocator.NewField(key, value)
+}
+
+func TestAllocator(t *testing.T) {
+	a := countingAllocator{interned: make(map[string]jtree.String)}
+	node, err := jtree.NewParser(strings.NewReader(`{"a": [1, "x", "x"], "b": 2.5}`), jtree.OpAllocator(&a)).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Object{
+			{"a", jtree.Array{newNumNode("1"), jtree.String("x"), jtree.String("x")}},
+			{"b", newNumNode("2.5")},
+		}, node)
+	}
+	assert.Equal(t, 2, a.nums)
+	assert.Equal(t, 2, a.strings)
+	assert.Equal(t, 2, a.fields)
+	assert.Len(t, a.interned, 1)
+}
+
+func TestArena(t *testing.T) {
+	a := jtree.NewArena()
+	var src strings.Builder
+	src.WriteString("[")
+	for i := 0; i < 1000; i++ {
+		if i != 0 {
+			src.WriteString(",")
+		}
+		src.WriteString(`{"v": 1.5}`)
+	}
+	src.WriteString("]")
+	for i := 0; i < 2; i++ {
+		node, err := jtree.NewParser(strings.NewReader(src.String()), jtree.OpAllocator(a)).Parse()
+		if assert.NoError(t, err) {
+			var dest []struct {
+				V float64 `json:"v"`
+			}
+			if assert.NoError(t, node.Decode(&dest)) {
+				assert.Len(t, dest, 1000)
+				assert.Equal(t, 1.5, dest[999].V)
+			}
+		}
+		a.Release()
+	}
+}
@@ -0,0 +1,106 @@
+package jtree
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Annotations holds user data attached to tree nodes by their identity, so annotations follow the nodes when
+// the tree is rearranged and are dropped together with the nodes when they are replaced. The target may be an object
+// member (*Field), a number (*Num) or a non empty Object, Array or Raw node, the latter keep their identity as long
+// as their backing array is not reallocated. Other nodes are plain values without identity and must be annotated
+// through the member or the container holding them. The zero value is ready to use
+type Annotations struct {
+	m map[interface{}]*annotated
+}
+
+type annotated struct {
+	target interface{} // keeps the target alive so its address can't be reused
+	values map[string]interface{}
+}
+
+type backingArray struct {
+	t reflect.Type
+	p uintptr
+}
+
+// annotationKey returns the identity of the target
+func annotationKey(target interface{}) (interface{}, bool) {
+	switch t := target.(type) {
+	case *Field:
+		return t, t != nil
+	case *Num:
+		return t, t != nil
+	case Object, Array, Raw:
+		v := reflect.ValueOf(t)
+		if v.Cap() == 0 {
+			return nil, false
+		}
+		return backingArray{t: v.Type(), p: v.Pointer()}, true
+	}
+	return nil, false
+}
+
+// Set attaches the value to the target under the key. It returns false if the target has no identity
+func (a *Annotations) Set(target interface{}, key string, value interface{}) bool {
+	k, ok := annotationKey(target)
+	if !ok {
+		return false
+	}
+	if a.m == nil {
+		a.m = make(map[interface{}]*annotated)
+	}
+	e, ok := a.m[k]
+	if !ok {
+		e = &annotated{target: target, values: make(map[string]interface{})}
+		a.m[k] = e
+	}
+	e.values[key] = value
+	return true
+}
+
+// Get returns the value attached to the target under the key
+func (a *Annotations) Get(target interface{}, key string) (interface{}, bool) {
+	k, ok := annotationKey(target)
+	if !ok {
+		return nil, false
+	}
+	if e, ok := a.m[k]; ok {
+		v, ok := e.values[key]
+		return v, ok
+	}
+	return nil, false
+}
+
+// Delete removes the value attached to the target under the key
+func (a *Annotations) Delete(target interface{}, key string) {
+	k, ok := annotationKey(target)
+	if !ok {
+		return
+	}
+	if e, ok := a.m[k]; ok {
+		delete(e.values, key)
+		if len(e.values) == 0 {
+			delete(a.m, k)
+		}
+	}
+}
+
+// Keys returns sorted keys of values attached to the target
+func (a *Annotations) Keys(target interface{}) []string {
+	var values map[string]interface{}
+	if k, ok := annotationKey(target); ok {
+		if e, ok := a.m[k]; ok {
+			values = e.values
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of annotated targets
+func (a *Annotations) Len() int { return len(a.m) }
@@ -0,0 +1,58 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotations(t *testing.T) {
+	root := mustParse(t, `{"a": [{"x": 1}, "s", 2], "b": "str"}`).(jtree.Object)
+	list := root.FieldByName("a").(jtree.Array)
+	rec := list[0].(jtree.Object)
+
+	var a jtree.Annotations
+	_, ok := a.Get(rec, "x")
+	assert.False(t, ok)
+
+	assert.True(t, a.Set(rec, "type", "record"))
+	assert.True(t, a.Set(rec, "valid", true))
+	assert.True(t, a.Set(root[1], "type", "string"))
+	assert.True(t, a.Set(list[2], "type", "integer"))
+	// plain values have no identity
+	assert.False(t, a.Set(list[1], "type", "string"))
+	assert.False(t, a.Set(jtree.Object{}, "type", "object"))
+
+	v, ok := a.Get(rec, "type")
+	assert.True(t, ok)
+	assert.Equal(t, "record", v)
+	assert.Equal(t, []string{"type", "valid"}, a.Keys(rec))
+	assert.Equal(t, 3, a.Len())
+
+	// annotations follow the nodes
+	list.Insert(0, jtree.Null{}, jtree.Null{})
+	root.Delete("a")
+	root.InsertAt(0, "c", jtree.Bool(true))
+	v, _ = a.Get(list[2], "type")
+	assert.Equal(t, "record", v)
+	v, _ = a.Get(list[4], "type")
+	assert.Equal(t, "integer", v)
+	_, ok = a.Get(list[3], "type")
+	assert.False(t, ok)
+	v, _ = a.Get(root[1], "type")
+	assert.Equal(t, "string", v)
+	_, ok = a.Get(root[0], "type")
+	assert.False(t, ok)
+
+	// replaced members lose their annotations
+	root.Set("b", jtree.String("other"))
+	_, ok = a.Get(root[1], "type")
+	assert.False(t, ok)
+
+	a.Delete(rec, "type")
+	a.Delete(rec, "valid")
+	a.Delete(list[3], "valid")
+	assert.Equal(t, []string{}, a.Keys(rec))
+	assert.Equal(t, 2, a.Len())
+}
@@ -0,0 +1,79 @@
+package jtree
+
+import "fmt"
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() (idx [256]int8) {
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i, c := range []byte(base58Alphabet) {
+		idx[c] = int8(i)
+	}
+	return
+}()
+
+type base58Encoding struct{}
+
+func (base58Encoding) Encode(src []byte) []byte {
+	zeros := 0
+	for zeros < len(src) && src[zeros] == 0 {
+		zeros++
+	}
+	// log(256)/log(58) ~ 1.37
+	buf := make([]byte, (len(src)-zeros)*138/100+1)
+	n := 0
+	for _, b := range src[zeros:] {
+		carry := int(b)
+		for i := 0; i < n; i++ {
+			carry += int(buf[i]) << 8
+			buf[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry != 0 {
+			buf[n] = byte(carry % 58)
+			carry /= 58
+			n++
+		}
+	}
+	out := make([]byte, zeros+n)
+	for i := 0; i < zeros; i++ {
+		out[i] = base58Alphabet[0]
+	}
+	for i := 0; i < n; i++ {
+		out[zeros+i] = base58Alphabet[buf[n-1-i]]
+	}
+	return out
+}
+
+func (base58Encoding) Decode(src []byte) ([]byte, error) {
+	zeros := 0
+	for zeros < len(src) && src[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	// log(58)/log(256) ~ 0.733
+	buf := make([]byte, (len(src)-zeros)*733/1000+1)
+	n := 0
+	for i, c := range src[zeros:] {
+		carry := int(base58Index[c])
+		if carry < 0 {
+			return nil, fmt.Errorf("jtree: illegal base58 data at input byte %d", zeros+i)
+		}
+		for j := 0; j < n; j++ {
+			carry += int(buf[j]) * 58
+			buf[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry != 0 {
+			buf[n] = byte(carry)
+			carry >>= 8
+			n++
+		}
+	}
+	out := make([]byte, zeros+n)
+	for i := 0; i < n; i++ {
+		out[zeros+i] = buf[n-1-i]
+	}
+	return out, nil
+}
@@ -0,0 +1,104 @@
+package jtree
+
+import (
+	"math"
+	"math/big"
+)
+
+// ObjectBuilder constructs Object nodes. Repeated keys are appended as is
+type ObjectBuilder struct {
+	obj Object
+}
+
+// NewObjectBuilder returns a new empty object builder
+func NewObjectBuilder() *ObjectBuilder {
+	return &ObjectBuilder{obj: make(Object, 0)}
+}
+
+// Node appends the field with arbitrary value
+func (b *ObjectBuilder) Node(key string, v Node) *ObjectBuilder {
+	b.obj = append(b.obj, &Field{Key: key, Value: v})
+	return b
+}
+
+// Str appends the string field
+func (b *ObjectBuilder) Str(key string, v string) *ObjectBuilder { return b.Node(key, String(v)) }
+
+// floatNode returns the numeric node or NaN which big.Float can't represent
+func floatNode(v float64) Node {
+	if math.IsNaN(v) {
+		return NaN{}
+	}
+	return (*Num)(big.NewFloat(v))
+}
+
+// Num appends the numeric field. NaN is stored as the NaN node
+func (b *ObjectBuilder) Num(key string, v float64) *ObjectBuilder { return b.Node(key, floatNode(v)) }
+
+// Int appends the integer field
+func (b *ObjectBuilder) Int(key string, v int64) *ObjectBuilder {
+	return b.Node(key, (*Num)(new(big.Float).SetInt64(v)))
+}
+
+// Bool appends the boolean field
+func (b *ObjectBuilder) Bool(key string, v bool) *ObjectBuilder { return b.Node(key, Bool(v)) }
+
+// Null appends the null field
+func (b *ObjectBuilder) Null(key string) *ObjectBuilder { return b.Node(key, Null{}) }
+
+// Build returns the constructed object. The builder must not be used afterwards
+func (b *ObjectBuilder) Build() Object { return b.obj }
+
+// ArrayBuilder constructs Array nodes
+type ArrayBuilder struct {
+	arr Array
+}
+
+// NewArrayBuilder returns a new empty array builder
+func NewArrayBuilder() *ArrayBuilder {
+	return &ArrayBuilder{arr: make(Array, 0)}
+}
+
+// Node appends arbitrary elements
+func (b *ArrayBuilder) Node(v ...Node) *ArrayBuilder {
+	b.arr = append(b.arr, v...)
+	return b
+}
+
+// Str appends string elements
+func (b *ArrayBuilder) Str(v ...string) *ArrayBuilder {
+	for _, s := range v {
+		b.arr = append(b.arr, String(s))
+	}
+	return b
+}
+
+// Num appends numeric elements. NaN is stored as the NaN node
+func (b *ArrayBuilder) Num(v ...float64) *ArrayBuilder {
+	for _, x := range v {
+		b.arr = append(b.arr, floatNode(x))
+	}
+	return b
+}
+
+// Int appends integer elements
+func (b *ArrayBuilder) Int(v ...int64) *ArrayBuilder {
+	for _, x := range v {
+		b.arr = append(b.arr, (*Num)(new(big.Float).SetInt64(x)))
+	}
+	return b
+}
+
+// Bool appends boolean elements
+func (b *ArrayBuilder) Bool(v ...bool) *ArrayBuilder {
+	for _, x := range v {
+		b.arr = append(b.arr, Bool(x))
+	}
+	return b
+}
+
+// Null appends the null element
+func (b *ArrayBuilder) Null() *ArrayBuilder { return b.Node(Null{}) }
+
+// Build returns the constructed array. The builder must not be used afterwards
+func (b *ArrayBuilder) Build() Array { return b.arr }
@@ -0,0 +1,30 @@
+package jtree_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	n := jtree.NewObjectBuilder().
+		Str("name", "x").
+		Num("n", 1.5).
+		Int("i", -2).
+		Bool("ok", true).
+		Null("none").
+		Node("list", jtree.NewArrayBuilder().Str("a", "b").Int(1).Num(0.5).Bool(false).Null().Build()).
+		Node("obj", jtree.NewObjectBuilder().Build()).
+		Build()
+
+	assert.True(t, jtree.Equal(mustParse(t, `{"name": "x", "n": 1.5, "i": -2, "ok": true, "none": null, "list": ["a", "b", 1, 0.5, false, null], "obj": {}}`), n, jtree.OpOrderedKeys))
+
+	n = jtree.NewObjectBuilder().Num("nan", math.NaN()).Node("list", jtree.NewArrayBuilder().Num(math.NaN(), math.Inf(1)).Build()).Build()
+	assert.Equal(t, jtree.NaN{}, n.FieldByName("nan"))
+	list := n.FieldByName("list").(jtree.Array)
+	assert.Equal(t, jtree.NaN{}, list[0])
+	assert.True(t, (*big.Float)(list[1].(*jtree.Num)).IsInf())
+}
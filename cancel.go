@@ -0,0 +1,56 @@
+package jtree
+
+import "context"
+
+// cancelInterval is the number of decoded nodes or parsed tokens between checks of the context
+const cancelInterval = 1024
+
+type canceler struct {
+	ctx   context.Context
+	ticks int
+}
+
+func (c *canceler) check() error {
+	if c.ticks%cancelInterval == 0 {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	c.ticks++
+	return nil
+}
+
+// DecodeContext decodes the node into the value pointed by v like n.Decode(v, op...) but aborts with ctx.Err()
+// when ctx is canceled or its deadline is exceeded. The context is checked periodically
+func DecodeContext(ctx context.Context, n Node, v interface{}, op ...Option) error {
+	op = append(op[:len(op):len(op)], func(o *options) { o.mutableCtx().cancel = &canceler{ctx: ctx} })
+	return n.Decode(v, op...)
+}
+
+// ParseContext is the Parse equivalent aborting with ctx.Err() when ctx is canceled or its deadline is exceeded.
+// The context is checked periodically
+func (p *Parser) ParseContext(ctx context.Context) (Node, error) {
+	p.cancel = &canceler{ctx: ctx}
+	defer func() { p.cancel = nil }()
+	return p.Parse()
+}
+
+// DecodeContext parses the next value and decodes it into the value pointed by v aborting with ctx.Err()
+// when ctx is canceled or its deadline is exceeded
+func (dec *Decoder) DecodeContext(ctx context.Context, v interface{}, op ...Option) error {
+	p, err := dec.parser()
+	if err != nil {
+		return err
+	}
+	op = append(dec.opt[:len(dec.opt):len(dec.opt)], op...)
+	if dec.direct && p.canDecodeDirect() {
+		p.cancel = &canceler{ctx: ctx}
+		defer func() { p.cancel = nil }()
+		return p.decodeDirect(v, append(op, func(o *options) { o.mutableCtx().cancel = p.cancel }))
+	}
+	n, err := p.ParseContext(ctx)
+	if err != nil {
+		return err
+	}
+	return DecodeContext(ctx, n, v, op...)
+}
@@ -0,0 +1,82 @@
+package jtree
+
+import (
+	"math/big"
+	"reflect"
+)
+
+// Coercion is the cross-kind conversion rule. It returns the replacement node decoded into the target type
+// instead of the original one and true if the rule is applicable
+type Coercion func(node Node, target reflect.Type) (Node, bool)
+
+// RegisterCoercion adds the coercion rule consulted before the built-in conversion logic of concrete types.
+// Rules are consulted in the order of addition, rules of the registry take precedence over its parent's ones
+func (r *TypeRegistry) RegisterCoercion(c Coercion) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.coercions = append(r.coercions, c)
+}
+
+func (r *TypeRegistry) coerce(node Node, t reflect.Type) (Node, bool) {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		coercions := r.coercions
+		r.mtx.RUnlock()
+		for _, c := range coercions {
+			if n, ok := c(node, t); ok {
+				return n, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (r *TypeRegistry) hasCoercions() bool {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		n := len(r.coercions)
+		r.mtx.RUnlock()
+		if n != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterCoercion adds the coercion rule to the global registry
+func RegisterCoercion(c Coercion) {
+	DefaultTypeRegistry().RegisterCoercion(c)
+}
+
+// CoerceNumericStrings makes strings containing numbers to be decoded into numeric types without OpString
+func CoerceNumericStrings(node Node, target reflect.Type) (Node, bool) {
+	s, ok := node.(String)
+	if !ok {
+		return nil, false
+	}
+	k := target.Kind()
+	if k < reflect.Int || k > reflect.Float64 {
+		return nil, false
+	}
+	f, _, err := new(big.Float).Parse(string(s), 10)
+	if err != nil {
+		return nil, false
+	}
+	return (*Num)(f), true
+}
+
+// CoerceBoolStrings makes "true" and "false" strings to be decoded into boolean values
+func CoerceBoolStrings(node Node, target reflect.Type) (Node, bool) {
+	s, ok := node.(String)
+	if !ok || target.Kind() != reflect.Bool {
+		return nil, false
+	}
+	switch s {
+	case "true":
+		return Bool(true), true
+	case "false":
+		return Bool(false), true
+	default:
+		return nil, false
+	}
+}
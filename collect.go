@@ -0,0 +1,83 @@
+package jtree
+
+import (
+	"errors"
+	"strings"
+)
+
+// DecodeError is the error of decoding the value located by Path. Returned by Decode joined with errors.Join
+// when OpCollectErrors is set
+type DecodeError struct {
+	Path []string // object keys and decimal array indices leading to the value
+	Err  error
+}
+
+// Pointer returns the location of the failed value as JSON pointer
+func (e *DecodeError) Pointer() string {
+	var b strings.Builder
+	for _, p := range e.Path {
+		b.WriteByte('/')
+		b.WriteString(pointerEscaper.Replace(p))
+	}
+	return b.String()
+}
+
+func (e *DecodeError) Error() string { return e.Pointer() + ": " + e.Err.Error() }
+
+// Unwrap returns the underlying error
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// OpCollectErrors makes Decode to continue after failures of struct fields, map members and array elements
+// and to return all errors joined with errors.Join. Every joined error is *DecodeError.
+// The option is global for all Decode calls in chain
+func OpCollectErrors(o *options) { o.mutableCtx().collect = true }
+
+// errorCollector accumulates errors of container members in the collecting mode
+type errorCollector struct {
+	collect bool
+	errs    []error
+}
+
+func newErrorCollector(opt *options) *errorCollector {
+	return &errorCollector{collect: opt.ctx().collect}
+}
+
+// add records the error of the member located by key and returns false if the decoding must stop.
+// Empty key means the container itself
+func (c *errorCollector) add(key string, err error) bool {
+	if err == nil {
+		return true
+	}
+	if !c.collect {
+		c.errs = append(c.errs, err)
+		return false
+	}
+	var errs []error
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = j.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+	for _, e := range errs {
+		de, ok := e.(*DecodeError)
+		if !ok {
+			de = &DecodeError{Path: []string{}, Err: e}
+		}
+		if key != "" {
+			de.Path = append([]string{key}, de.Path...)
+		}
+		c.errs = append(c.errs, de)
+	}
+	return true
+}
+
+func (c *errorCollector) err() error {
+	switch {
+	case len(c.errs) == 0:
+		return nil
+	case !c.collect:
+		return c.errs[0]
+	default:
+		return errors.Join(c.errs...)
+	}
+}
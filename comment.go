@@ -0,0 +1,66 @@
+package jtree
+
+// Comment is the source comment associated with the nearest value by its path. Leading comments precede the value,
+// trailing ones follow it on the same line. Comments left at the end of a container are associated with the container
+// as trailing ones. Comments aren't stored in the tree, so the paths refer to the tree as parsed and aren't updated
+// when the tree is modified
+type Comment struct {
+	Path     []string // object keys and decimal array indices leading to the value
+	Text     string   // including delimiters i.e. "// text" or "/* text */"
+	Offset   int64
+	Trailing bool
+}
+
+// OpAllowComments makes the lexer to accept // and /* */ comments. Comments are collected by the parser and
+// returned by Parser.Comments, the parsed nodes don't carry them
+func OpAllowComments(o *parserOptions) { o.comments = true }
+
+// Comments returns comments collected during the last Parse call
+func (p *Parser) Comments() []Comment {
+	return p.comments
+}
+
+func (p *Parser) attach(c rawComment, path []string, trailing bool) {
+	p.comments = append(p.comments, Comment{
+		Path:     append([]string{}, path...),
+		Text:     c.text,
+		Offset:   c.pos,
+		Trailing: trailing,
+	})
+}
+
+// collectComments sorts out comments preceding tok. Same line comments following the last value are attached to it
+// unless the block comment is followed by another value. Others are kept to be attached to the next value
+func (p *Parser) collectComments(tok token) {
+	sep := tok.kind == tokNone || tok.delim(',') || tok.delim(']') || tok.delim('}') || tok.delim(':')
+	for _, c := range p.r.comments {
+		if p.last != nil && !c.newline && (!c.block || sep) {
+			p.attach(c, p.last, true)
+		} else {
+			p.last = nil
+			p.leading = append(p.leading, c)
+		}
+	}
+	p.r.comments = p.r.comments[:0]
+}
+
+// leadingComments attaches pending comments to the value at the current path
+func (p *Parser) leadingComments() {
+	for _, c := range p.leading {
+		p.attach(c, p.path, false)
+	}
+	p.leading = p.leading[:0]
+}
+
+// endComments attaches the rest of comments to the value at the current path as trailing ones and
+// marks it as the last parsed one
+func (p *Parser) endComments() {
+	for _, c := range p.leading {
+		p.attach(c, p.path, true)
+	}
+	p.leading = p.leading[:0]
+	if p.last == nil {
+		p.last = make([]string, 0, len(p.path))
+	}
+	p.last = append(p.last[:0], p.path...)
+}
@@ -0,0 +1,122 @@
+package jtree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Expr computes a value from the source object. Used with OpComputed
+type Expr func(src Object) (Node, error)
+
+// ExprRef returns the value referenced by the JSON Pointer relative to the source object
+func ExprRef(ptr string) Expr {
+	return func(src Object) (Node, error) { return Pointer(src, ptr) }
+}
+
+// ExprConst returns n
+func ExprConst(n Node) Expr {
+	return func(Object) (Node, error) { return n, nil }
+}
+
+// ExprConcat concatenates string representations of scalar arguments
+func ExprConcat(args ...Expr) Expr {
+	return func(src Object) (Node, error) {
+		var s strings.Builder
+		for _, arg := range args {
+			n, err := arg(src)
+			if err != nil {
+				return nil, err
+			}
+			switch v := n.(type) {
+			case String:
+				s.WriteString(string(v))
+			case *Num:
+				s.WriteString(v.text())
+			case Bool:
+				s.WriteString(strconv.FormatBool(bool(v)))
+			case Null:
+			default:
+				return nil, fmt.Errorf("jtree: can't concatenate %s", n.Type())
+			}
+		}
+		return String(s.String()), nil
+	}
+}
+
+func exprArith(op string, args []Expr, fn func(z, x, y *big.Float) error) Expr {
+	return func(src Object) (Node, error) {
+		var acc *big.Float
+		for _, arg := range args {
+			n, err := arg(src)
+			if err != nil {
+				return nil, err
+			}
+			num, ok := n.(*Num)
+			if !ok {
+				return nil, fmt.Errorf("jtree: %s: number expected: %s", op, n.Type())
+			}
+			if acc == nil {
+				acc = new(big.Float).Copy((*big.Float)(num))
+			} else if err := fn(acc, acc, (*big.Float)(num)); err != nil {
+				return nil, fmt.Errorf("jtree: %s: %w", op, err)
+			}
+		}
+		if acc == nil {
+			return nil, fmt.Errorf("jtree: %s: no arguments", op)
+		}
+		return (*Num)(acc), nil
+	}
+}
+
+// bigOp returns big.ErrNaN raised by fn on undefined results like Inf-Inf as an error
+func bigOp(fn func(z, x, y *big.Float) *big.Float) func(z, x, y *big.Float) error {
+	return func(z, x, y *big.Float) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				nan, ok := r.(big.ErrNaN)
+				if !ok {
+					panic(r)
+				}
+				err = nan
+			}
+		}()
+		fn(z, x, y)
+		return nil
+	}
+}
+
+// ExprAdd returns the sum of numeric arguments
+func ExprAdd(args ...Expr) Expr { return exprArith("add", args, bigOp((*big.Float).Add)) }
+
+// ExprSub subtracts the rest of numeric arguments from the first one
+func ExprSub(args ...Expr) Expr { return exprArith("sub", args, bigOp((*big.Float).Sub)) }
+
+// ExprMul returns the product of numeric arguments
+func ExprMul(args ...Expr) Expr { return exprArith("mul", args, bigOp((*big.Float).Mul)) }
+
+// ExprDiv divides the first numeric argument by the rest of them
+func ExprDiv(args ...Expr) Expr {
+	quo := bigOp((*big.Float).Quo)
+	return exprArith("div", args, func(z, x, y *big.Float) error {
+		if y.Sign() == 0 {
+			return errors.New("division by zero")
+		}
+		return quo(z, x, y)
+	})
+}
+
+// OpComputed assigns the result of the expression evaluated against the source object to the struct field
+// with specified JSON name. Computed fields are decoded after regular ones
+func OpComputed(field string, e Expr) Option {
+	return func(o *options) {
+		o.computed = append(o.computed, computedField{name: field, expr: e})
+	}
+}
+
+type computedField struct {
+	name string
+	expr Expr
+}
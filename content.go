@@ -0,0 +1,79 @@
+package jtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+type frontEnd int
+
+const (
+	frontJSON frontEnd = iota
+	frontLines
+	frontJSON5
+)
+
+func frontEndFor(contentType string) (frontEnd, error) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0, fmt.Errorf("jtree: %w", err)
+	}
+	if cs, ok := params["charset"]; ok && !strings.EqualFold(cs, "utf-8") && !strings.EqualFold(cs, "utf8") {
+		return 0, fmt.Errorf("jtree: unsupported charset: %s", cs)
+	}
+	switch mt {
+	case "application/json", "text/json":
+		return frontJSON, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines", "application/jsonlines":
+		return frontLines, nil
+	case "application/json5":
+		return frontJSON5, nil
+	}
+	if strings.HasSuffix(mt, "+json") {
+		return frontJSON, nil
+	}
+	return 0, fmt.Errorf("jtree: unsupported content type: %s", mt)
+}
+
+// DecodeAny parses the stream according to the MIME content type and decodes the result into the value pointed by v.
+// Supported types are application/json and +json suffixed types, and JSON Lines (application/x-ndjson, application/jsonl)
+// decoded as an array of values. application/json5 is only partially supported: single quoted strings, comments,
+// trailing commas, NaN and Infinity are accepted while unquoted keys, hexadecimal numbers, explicit plus signs
+// and multi-line strings are not
+func DecodeAny(r io.Reader, contentType string, v interface{}, op ...Option) error {
+	fe, err := frontEndFor(contentType)
+	if err != nil {
+		return err
+	}
+	var popt []ParserOption
+	if fe == frontJSON5 {
+		popt = []ParserOption{OpAllowSingleQuotes, OpAllowNonFinite, OpAllowComments}
+	}
+	p := NewParser(bufio.NewReader(r), popt...)
+	var n Node
+	if fe == frontLines {
+		array := make(Array, 0)
+		for {
+			elem, err := p.Parse()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			array = append(array, elem)
+		}
+		n = array
+	} else {
+		if n, err = p.Parse(); err != nil {
+			return err
+		}
+		if err := p.ExpectEOF(); err != nil {
+			return err
+		}
+	}
+	return n.Decode(v, op...)
+}
@@ -0,0 +1,37 @@
+package jtree_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeAny(t *testing.T) {
+	type item struct {
+		A int `json:"a"`
+	}
+
+	var v item
+	if assert.NoError(t, jtree.DecodeAny(strings.NewReader(`{"a": 1}`), "application/json; charset=utf-8", &v)) {
+		assert.Equal(t, item{A: 1}, v)
+	}
+	if assert.NoError(t, jtree.DecodeAny(strings.NewReader(`{"a": 2}`), "application/vnd.api+json", &v)) {
+		assert.Equal(t, item{A: 2}, v)
+	}
+	if assert.NoError(t, jtree.DecodeAny(strings.NewReader(`{'a': 3, // comment
+}`), "application/json5", &v)) {
+		assert.Equal(t, item{A: 3}, v)
+	}
+
+	var list []item
+	if assert.NoError(t, jtree.DecodeAny(strings.NewReader("{\"a\": 1}\n{\"a\": 2}\n"), "application/x-ndjson", &list)) {
+		assert.Equal(t, []item{{A: 1}, {A: 2}}, list)
+	}
+
+	assert.EqualError(t, jtree.DecodeAny(strings.NewReader(`{"a": 1} {}`), "application/json", &v), "jtree: unexpected data after top level value at position 9: '{'")
+	assert.EqualError(t, jtree.DecodeAny(strings.NewReader(`{a: 1}`), "application/json5", &v), "jtree: object key expected at position 1: 'a'")
+	assert.EqualError(t, jtree.DecodeAny(strings.NewReader(`a: 1`), "application/yaml", &v), "jtree: unsupported content type: application/yaml")
+	assert.EqualError(t, jtree.DecodeAny(strings.NewReader(`{}`), "application/json; charset=latin1", &v), "jtree: unsupported charset: latin1")
+}
@@ -0,0 +1,88 @@
+package jtree
+
+// ContextOption is the function pointer used to pass options to NewContext
+type ContextOption func(*Context)
+
+// NewContext returns the Context configured by opts. It can be built once and shared between decoders using OpCtx.
+// Global options passed along with OpCtx apply to the copy of the shared context
+func NewContext(opts ...ContextOption) *Context {
+	var ctx Context
+	for _, fn := range opts {
+		fn(&ctx)
+	}
+	return &ctx
+}
+
+// WithOptions applies global Decode options like OpStrictTypes to the context
+func WithOptions(op ...Option) ContextOption {
+	return func(c *Context) {
+		o := options{context: c, ownCtx: true}
+		o.apply(op)
+	}
+}
+
+// WithTypes is the OpTypes equivalent
+func WithTypes(r *TypeRegistry) ContextOption { return func(c *Context) { c.typeReg = r } }
+
+// WithEncodings is the OpEncodings equivalent
+func WithEncodings(r *EncodingRegistry) ContextOption { return func(c *Context) { c.encReg = r } }
+
+// WithStrictTypes is the OpStrictTypes equivalent
+func WithStrictTypes(c *Context) { c.strictTypes = true }
+
+// WithDisallowUnknownFields is the OpDisallowUnknownFields equivalent
+func WithDisallowUnknownFields(c *Context) { c.noUnknown = true }
+
+// WithRequireFields is the OpRequireFields equivalent
+func WithRequireFields(c *Context) { c.requireFields = true }
+
+// WithDisallowNull is the OpDisallowNull equivalent
+func WithDisallowNull(c *Context) { c.noNull = true }
+
+// WithMergeExisting is the OpMergeExisting equivalent
+func WithMergeExisting(c *Context) { c.merge = true }
+
+// WithCollectErrors is the OpCollectErrors equivalent
+func WithCollectErrors(c *Context) { c.collect = true }
+
+// WithProtoJSON is the OpProtoJSON equivalent
+func WithProtoJSON(c *Context) { c.proto = true }
+
+// WithInterfaceNumbers is the OpInterfaceNumbers equivalent
+func WithInterfaceNumbers(m NumberMode) ContextOption { return func(c *Context) { c.numbers = m } }
+
+// WithMaxDecodeDepth is the OpMaxDecodeDepth equivalent
+func WithMaxDecodeDepth(depth int) ContextOption { return func(c *Context) { c.maxDepth = depth } }
+
+// WithDecodeHook is the OpDecodeHook equivalent
+func WithDecodeHook(h DecodeHook) ContextOption {
+	return func(c *Context) { c.hooks = append(c.hooks[:len(c.hooks):len(c.hooks)], h) }
+}
+
+type contextValue struct {
+	key, val interface{}
+	next     *contextValue
+}
+
+// WithValue returns a copy of the context carrying val associated with key. Values can be retrieved by registered
+// constructors, field decoders and JSONContextDecoder implementations. Keys must be comparable
+func (c *Context) WithValue(key, val interface{}) *Context {
+	out := *c
+	out.values = &contextValue{key: key, val: val, next: c.values}
+	return &out
+}
+
+// Value returns the value associated with key or nil
+func (c *Context) Value(key interface{}) interface{} {
+	for v := c.values; v != nil; v = v.next {
+		if v.key == key {
+			return v.val
+		}
+	}
+	return nil
+}
+
+// WithValue is the Context.WithValue equivalent
+func WithValue(key, val interface{}) ContextOption {
+	return func(c *Context) { c.values = &contextValue{key: key, val: val, next: c.values} }
+}
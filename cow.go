@@ -0,0 +1,111 @@
+package jtree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// COW is the copy-on-write document. Forks share all nodes with the parent and modifications copy only the containers
+// along the modified path, leaving all other subtrees shared. Nodes reachable from COW must not be mutated in place
+type COW struct {
+	root Node
+}
+
+// NewCOW returns new copy-on-write document with the root node
+func NewCOW(root Node) *COW {
+	return &COW{root: root}
+}
+
+// Root returns the current root node
+func (c *COW) Root() Node { return c.root }
+
+// Fork returns an independent copy of the document sharing all nodes with the original one
+func (c *COW) Fork() *COW {
+	return &COW{root: c.root}
+}
+
+// Set replaces the value located by path consisting of object keys and decimal array indices. A missing object key
+// is appended to the object. "-" as the last path element appends the value to the array
+func (c *COW) Set(path []string, value Node) error {
+	root, err := cowUpdate(c.root, path, 0, value, false)
+	if err != nil {
+		return err
+	}
+	c.root = root
+	return nil
+}
+
+// Delete removes the object member or the array element located by path
+func (c *COW) Delete(path []string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("jtree: can't delete the root node")
+	}
+	root, err := cowUpdate(c.root, path, 0, nil, true)
+	if err != nil {
+		return err
+	}
+	c.root = root
+	return nil
+}
+
+// cowUpdate updates the copy of n located by path[at:]
+func cowUpdate(n Node, path []string, at int, value Node, del bool) (Node, error) {
+	if at == len(path) {
+		return value, nil
+	}
+	key, last := path[at], at == len(path)-1
+	loc := func() string { return strings.Join(path[:at+1], "/") }
+	switch node := n.(type) {
+	case Object:
+		i := 0
+		for ; i < len(node) && node[i].Key != key; i++ {
+		}
+		if i == len(node) {
+			if !last || del {
+				return nil, fmt.Errorf("jtree: key not found: %s", loc())
+			}
+			out := make(Object, len(node), len(node)+1)
+			copy(out, node)
+			return append(out, &Field{Key: key, Value: value}), nil
+		}
+		if last && del {
+			out := make(Object, 0, len(node)-1)
+			return append(append(out, node[:i]...), node[i+1:]...), nil
+		}
+		v, err := cowUpdate(node[i].Value, path, at+1, value, del)
+		if err != nil {
+			return nil, err
+		}
+		out := make(Object, len(node))
+		copy(out, node)
+		out[i] = &Field{Key: key, Value: v}
+		return out, nil
+
+	case Array:
+		if key == "-" && last && !del {
+			out := make(Array, len(node), len(node)+1)
+			copy(out, node)
+			return append(out, value), nil
+		}
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, fmt.Errorf("jtree: invalid array index: %s", loc())
+		}
+		if last && del {
+			out := make(Array, 0, len(node)-1)
+			return append(append(out, node[:i]...), node[i+1:]...), nil
+		}
+		v, err := cowUpdate(node[i], path, at+1, value, del)
+		if err != nil {
+			return nil, err
+		}
+		out := make(Array, len(node))
+		copy(out, node)
+		out[i] = v
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("jtree: %s node can't be indexed: %s", n.Type(), loc())
+	}
+}
@@ -0,0 +1,40 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCOW(t *testing.T) {
+	shared := jtree.Array{jtree.String("x"), jtree.String("y")}
+	src := jtree.Object{
+		{"a", jtree.Object{{"b", newNumNode("1")}, {"c", newNumNode("2")}}},
+		{"list", shared},
+	}
+	doc := jtree.NewCOW(src)
+	fork := doc.Fork()
+
+	assert.NoError(t, fork.Set([]string{"a", "b"}, jtree.String("new")))
+	assert.NoError(t, fork.Set([]string{"a", "d"}, jtree.Bool(true)))
+	assert.NoError(t, fork.Delete([]string{"a", "c"}))
+	assert.EqualError(t, fork.Set([]string{"list", "5"}, jtree.Null{}), "jtree: invalid array index: list/5")
+	assert.EqualError(t, fork.Set([]string{"a", "b", "c"}, jtree.Null{}), "jtree: string node can't be indexed: a/b/c")
+
+	assert.Equal(t, jtree.Object{
+		{"a", jtree.Object{{"b", jtree.String("new")}, {"d", jtree.Bool(true)}}},
+		{"list", shared},
+	}, fork.Root())
+	// the original is untouched
+	assert.Equal(t, jtree.Object{
+		{"a", jtree.Object{{"b", newNumNode("1")}, {"c", newNumNode("2")}}},
+		{"list", jtree.Array{jtree.String("x"), jtree.String("y")}},
+	}, doc.Root())
+	// the unmodified subtree is shared
+	assert.Same(t, &shared[0], &fork.Root().(jtree.Object)[1].Value.(jtree.Array)[0])
+
+	assert.NoError(t, doc.Set([]string{"list", "-"}, jtree.String("z")))
+	assert.Equal(t, jtree.Array{jtree.String("x"), jtree.String("y"), jtree.String("z")}, doc.Root().(jtree.Object)[1].Value)
+	assert.Len(t, shared, 2)
+}
@@ -1,9 +1,15 @@
 package jtree_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"net"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -320,3 +326,1086 @@ func (c *CanDecode) DecodeJSON(node jtree.Node) error {
 	}
 	return fmt.Errorf("string expected: %s", node.Type())
 }
+
+func TestInterfaceNumbers(t *testing.T) {
+	n := jtree.Object{
+		{"id", newNumNode("12345678901234567890")},
+		{"list", jtree.Array{newNumNode("1.5"), newNumNode("-2")}},
+	}
+
+	var dest interface{}
+	if assert.NoError(t, n.Decode(&dest, jtree.OpInterfaceNumbers(jtree.NumberJSON))) {
+		assert.Equal(t, map[string]interface{}{
+			"id":   json.Number("12345678901234567890"),
+			"list": []interface{}{json.Number("1.5"), json.Number("-2")},
+		}, dest)
+	}
+
+	dest = nil
+	if assert.NoError(t, n.Decode(&dest, jtree.OpInterfaceNumbers(jtree.NumberNode))) {
+		assert.Equal(t, map[string]interface{}{
+			"id":   newNumNode("12345678901234567890"),
+			"list": []interface{}{newNumNode("1.5"), newNumNode("-2")},
+		}, dest)
+	}
+
+	dest = nil
+	if assert.NoError(t, n.Decode(&dest, jtree.OpInterfaceNumbers(jtree.NumberText))) {
+		assert.Equal(t, map[string]interface{}{
+			"id":   jtree.Number("12345678901234567890"),
+			"list": []interface{}{jtree.Number("1.5"), jtree.Number("-2")},
+		}, dest)
+		id := dest.(map[string]interface{})["id"].(jtree.Number)
+		_, err := id.Int64()
+		assert.Error(t, err)
+		i, err := id.BigInt()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "12345678901234567890", i.String())
+		}
+		f, err := id.Float64()
+		if assert.NoError(t, err) {
+			assert.Equal(t, 12345678901234567890.0, f)
+		}
+	}
+
+	var num jtree.Number
+	if assert.NoError(t, newNumNode("-2").Decode(&num)) {
+		v, err := num.Int64()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-2), v)
+	}
+}
+
+func TestProtoJSON(t *testing.T) {
+	type msg struct {
+		Timeout time.Duration `json:"timeout"`
+		Neg     time.Duration `json:"neg"`
+		Created time.Time     `json:"created"`
+		Data    []byte        `json:"data"`
+		URLData []byte        `json:"urlData"`
+		ID      int64         `json:"id"`
+		Count   uint64        `json:"count"`
+		Ratio   float64       `json:"ratio"`
+		Opt     *int32        `json:"opt"`
+		Missing *int32        `json:"missing"`
+	}
+	src := `{
+		"timeout": "3.5s",
+		"neg": "-0.000000001s",
+		"created": "2021-11-11T15:08:52.537Z",
+		"data": "YWFh",
+		"urlData": "-_8",
+		"id": "-9223372036854775808",
+		"count": 18446744073709551615,
+		"ratio": "Infinity",
+		"opt": 5,
+		"missing": null
+	}`
+	var dest msg
+	assert.EqualError(t, jtree.Unmarshal([]byte(src), &dest), "jtree: illegal base64 data at input byte 0")
+
+	node, err := jtree.NewParser(strings.NewReader(src)).Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.NoError(t, node.Decode(&dest, jtree.OpProtoJSON)) {
+		assert.Equal(t, 3500*time.Millisecond, dest.Timeout)
+		assert.Equal(t, -time.Nanosecond, dest.Neg)
+		assert.Equal(t, *mkTime("2021-11-11T15:08:52.537Z"), dest.Created)
+		assert.Equal(t, []byte("aaa"), dest.Data)
+		assert.Equal(t, []byte{0xfb, 0xff}, dest.URLData)
+		assert.Equal(t, int64(math.MinInt64), dest.ID)
+		assert.Equal(t, uint64(math.MaxUint64), dest.Count)
+		assert.Equal(t, math.Inf(1), dest.Ratio)
+		assert.Equal(t, int32(5), *dest.Opt)
+		assert.Nil(t, dest.Missing)
+	}
+
+	var d time.Duration
+	assert.EqualError(t, jtree.String("1h").Decode(&d, jtree.OpProtoJSON), "jtree: invalid duration: 1h")
+}
+
+func TestComputedFields(t *testing.T) {
+	type person struct {
+		First    string  `json:"first"`
+		Last     string  `json:"last"`
+		FullName string  `json:"fullName"`
+		Total    float64 `json:"total"`
+	}
+	src := jtree.Object{
+		{"first", jtree.String("John")},
+		{"last", jtree.String("Doe")},
+		{"price", newNumNode("2.5")},
+		{"qty", newNumNode("4")},
+	}
+	op := []jtree.Option{
+		jtree.OpComputed("fullName", jtree.ExprConcat(jtree.ExprRef("/first"), jtree.ExprConst(jtree.String(" ")), jtree.ExprRef("/last"))),
+		jtree.OpComputed("total", jtree.ExprAdd(jtree.ExprMul(jtree.ExprRef("/price"), jtree.ExprRef("/qty")), jtree.ExprConst(newNumNode("1")))),
+	}
+	var dest person
+	if assert.NoError(t, src.Decode(&dest, op...)) {
+		assert.Equal(t, person{First: "John", Last: "Doe", FullName: "John Doe", Total: 11}, dest)
+	}
+
+	var list []person
+	if assert.NoError(t, jtree.Array{src}.Decode(&list, jtree.OpElem(op...))) {
+		assert.Equal(t, []person{{First: "John", Last: "Doe", FullName: "John Doe", Total: 11}}, list)
+	}
+
+	assert.EqualError(t, src.Decode(&dest, jtree.OpComputed("total", jtree.ExprAdd(jtree.ExprRef("/first")))), "jtree: add: number expected: string")
+	assert.EqualError(t, src.Decode(&dest, jtree.OpComputed("x", jtree.ExprRef("/first"))), "jtree: undefined computed field 'x': jtree_test.person")
+
+	// undefined results are reported instead of panicking
+	inf := jtree.ExprConst((*jtree.Num)(new(big.Float).SetInf(false)))
+	negInf := jtree.ExprConst((*jtree.Num)(new(big.Float).SetInf(true)))
+	zero := jtree.ExprConst(newNumNode("0"))
+	for _, tc := range []struct {
+		expr   jtree.Expr
+		expect string
+	}{
+		{jtree.ExprSub(inf, inf), "jtree: sub: subtraction of infinities with equal signs"},
+		{jtree.ExprAdd(inf, negInf), "jtree: add: addition of infinities with opposite signs"},
+		{jtree.ExprMul(zero, inf), "jtree: mul: multiplication of zero with infinity"},
+		{jtree.ExprDiv(inf, inf), "jtree: div: division of zero by zero or infinity by infinity"},
+		{jtree.ExprDiv(zero, zero), "jtree: div: division by zero"},
+		{jtree.ExprDiv(inf, zero), "jtree: div: division by zero"},
+	} {
+		_, err := tc.expr(src)
+		assert.EqualError(t, err, tc.expect)
+	}
+	assert.EqualError(t, src.Decode(&dest, jtree.OpComputed("total", jtree.ExprSub(inf, inf))), "jtree: sub: subtraction of infinities with equal signs")
+}
+
+func TestOptional(t *testing.T) {
+	type patch struct {
+		Name  jtree.Optional[string]  `json:"name"`
+		Age   jtree.Optional[int]     `json:"age"`
+		Email jtree.Optional[*string] `json:"email"`
+		Count jtree.Optional[int]     `json:"count,string"`
+	}
+	var dest patch
+	src := mustParse(t, `{"name": "x", "email": null, "count": "3"}`)
+	if assert.NoError(t, src.Decode(&dest)) {
+		v, ok := dest.Name.Get()
+		assert.True(t, ok)
+		assert.Equal(t, "x", v)
+		assert.Equal(t, jtree.Optional[int]{}, dest.Age)
+		assert.Equal(t, jtree.Optional[*string]{Present: true, Null: true}, dest.Email)
+		assert.Equal(t, jtree.Optional[int]{Value: 3, Present: true}, dest.Count)
+	}
+	assert.Error(t, mustParse(t, `{"age": "x"}`).Decode(&dest))
+}
+
+type jsonUnmarshaler struct {
+	raw string
+}
+
+func (u *jsonUnmarshaler) UnmarshalJSON(data []byte) error {
+	if string(data) == `"bad"` {
+		return errors.New("bad value")
+	}
+	u.raw = string(data)
+	return nil
+}
+
+func TestJSONUnmarshalerFallback(t *testing.T) {
+	var dest struct {
+		A jsonUnmarshaler  `json:"a"`
+		B *jsonUnmarshaler `json:"b"`
+		C []jsonUnmarshaler
+		T time.Time `json:"t"`
+	}
+	src := mustParse(t, `{"a": {"x": [1, "y"]}, "b": 1.5, "C": [true, null], "t": "2021-01-02T03:04:05Z"}`)
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, `{"x":[1,"y"]}`, dest.A.raw)
+		assert.Equal(t, `1.5`, dest.B.raw)
+		assert.Equal(t, []jsonUnmarshaler{{raw: "true"}, {}}, dest.C)
+		assert.Equal(t, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), dest.T)
+	}
+	assert.EqualError(t, mustParse(t, `{"a": "bad"}`).Decode(&dest), "jtree: bad value")
+}
+
+func TestRawMessage(t *testing.T) {
+	var dest struct {
+		A jtree.RawMessage  `json:"a"`
+		B json.RawMessage   `json:"b"`
+		C []json.RawMessage `json:"c"`
+		D json.RawMessage   `json:"d"`
+	}
+	src := mustParse(t, `{"a": [1, {"x": "y"}], "b": {"z": 1.50}, "c": [null, "s"], "d": null}`)
+	src.(jtree.Object)[1].Value = jtree.Raw(`{"z": 1.50}`)
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, jtree.RawMessage(`[1,{"x":"y"}]`), dest.A)
+		assert.Equal(t, json.RawMessage(`{"z": 1.50}`), dest.B)
+		assert.Equal(t, []json.RawMessage{json.RawMessage(`null`), json.RawMessage(`"s"`)}, dest.C)
+		assert.Equal(t, json.RawMessage(`null`), dest.D)
+	}
+
+	// user defined nodes render themselves, missing values are reported
+	src = jtree.Object{{Key: "a", Value: jtree.Array{upperNode{"x"}, jtree.Object{{Key: "y", Value: upperNode{"z"}}}}}}
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, jtree.RawMessage(`["X",{"y":"Z"}]`), dest.A)
+	}
+	src = jtree.Object{{Key: "a", Value: jtree.Object{{Key: "x"}}}}
+	assert.EqualError(t, src.Decode(&dest), "jtree: can't encode nil node")
+}
+
+func TestRequiredFields(t *testing.T) {
+	type inner struct {
+		ID string `json:"id,required"`
+	}
+	type item struct {
+		inner
+		Name  string                 `json:"name,required"`
+		Note  string                 `json:"note"`
+		Extra string                 `json:"extra,omitempty"`
+		Opt   jtree.Optional[string] `json:"opt"`
+	}
+	var dest item
+	assert.NoError(t, mustParse(t, `{"id": "1", "name": "x"}`).Decode(&dest))
+	assert.EqualError(t, mustParse(t, `{"note": "x"}`).Decode(&dest), "jtree: missing required fields id, name: jtree_test.item")
+	assert.EqualError(t, mustParse(t, `{"id": "1", "name": "x"}`).Decode(&dest, jtree.OpRequireFields), "jtree: missing required fields note: jtree_test.item")
+	assert.NoError(t, mustParse(t, `{"id": "1", "name": "x", "note": null}`).Decode(&dest, jtree.OpRequireFields))
+
+	var list []item
+	assert.EqualError(t, mustParse(t, `[{"id": "1", "name": "x"}, {"id": "2"}]`).Decode(&list), "jtree: missing required fields name: jtree_test.item")
+}
+
+func TestDuration(t *testing.T) {
+	var dest struct {
+		A time.Duration   `json:"a"`
+		B time.Duration   `json:"b"`
+		C time.Duration   `json:"c,unit=s"`
+		D time.Duration   `json:"d,unit=ms"`
+		E []time.Duration `json:"e,[unit=m]"`
+	}
+	src := mustParse(t, `{"a": "1h30m", "b": 1500, "c": 2.5, "d": "250", "e": [1, "2s"]}`)
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, 90*time.Minute, dest.A)
+		assert.Equal(t, 1500*time.Nanosecond, dest.B)
+		assert.Equal(t, 2500*time.Millisecond, dest.C)
+		assert.Equal(t, 250*time.Millisecond, dest.D)
+		assert.Equal(t, []time.Duration{time.Minute, 2 * time.Second}, dest.E)
+	}
+
+	var d time.Duration
+	if assert.NoError(t, newNumNode("3").Decode(&d, jtree.OpDurationUnit(time.Hour))) {
+		assert.Equal(t, 3*time.Hour, d)
+	}
+	assert.EqualError(t, jtree.String("xx").Decode(&d), `jtree: time: invalid duration "xx"`)
+	assert.EqualError(t, newNumNode("1e7").Decode(&d, jtree.OpDurationUnit(time.Hour)), "jtree: number 1e+07 overflows time.Duration")
+	assert.EqualError(t, jtree.String("-1e7").Decode(&d, jtree.OpDurationUnit(time.Hour)), "jtree: number -1e+07 overflows time.Duration")
+
+	type badUnit struct {
+		A time.Duration `json:"a,unit=sec"`
+	}
+	var bad badUnit
+	assert.EqualError(t, mustParse(t, `{}`).Decode(&bad), "jtree: field A of jtree_test.badUnit: invalid duration unit 'sec'")
+}
+
+func TestArrayStreaming(t *testing.T) {
+	src := mustParse(t, `[{"id": 1}, {"id": 2}, {"id": 3}]`)
+	type item struct {
+		ID int `json:"id"`
+	}
+
+	var got []item
+	fn := func(v item) error {
+		if v.ID == 3 {
+			return errors.New("stop")
+		}
+		got = append(got, v)
+		return nil
+	}
+	assert.EqualError(t, src.Decode(fn), "stop")
+	assert.Equal(t, []item{{1}, {2}}, got)
+
+	ch := make(chan item, 3)
+	if assert.NoError(t, src.Decode(ch)) {
+		assert.Equal(t, []item{{1}, {2}, {3}}, []item{<-ch, <-ch, <-ch})
+	}
+
+	var dest struct {
+		Items chan<- int `json:"items"`
+	}
+	ch2 := make(chan int)
+	dest.Items = ch2
+	obj := mustParse(t, `{"items": [1, 2]}`)
+	done := make(chan error, 1)
+	go func() {
+		done <- obj.Decode(&dest)
+		close(ch2)
+	}()
+	var ints []int
+	for v := range ch2 {
+		ints = append(ints, v)
+	}
+	assert.NoError(t, <-done)
+	assert.Equal(t, []int{1, 2}, ints)
+
+	assert.EqualError(t, src.Decode(func(item) {}), "jtree: func(T) error expected: func(jtree_test.item)")
+	assert.EqualError(t, src.Decode((chan item)(nil)), "jtree: nil chan jtree_test.item")
+}
+
+func TestDecodeHook(t *testing.T) {
+	ipType := reflect.TypeOf(net.IP{})
+	hook := jtree.OpDecodeHook(func(node jtree.Node, target reflect.Type) (interface{}, bool, error) {
+		if target != ipType {
+			return nil, false, nil
+		}
+		s, ok := node.(jtree.String)
+		if !ok {
+			return nil, true, nil
+		}
+		ip := net.ParseIP(string(s))
+		if ip == nil {
+			return nil, false, fmt.Errorf("invalid IP address: %s", s)
+		}
+		return ip, true, nil
+	})
+	var dest struct {
+		Addr  net.IP   `json:"addr"`
+		Ptr   *net.IP  `json:"ptr"`
+		List  []net.IP `json:"list"`
+		Empty net.IP   `json:"empty"`
+	}
+	src := mustParse(t, `{"addr": "10.0.0.1", "ptr": "::1", "list": ["1.2.3.4"], "empty": 0}`)
+	if assert.NoError(t, src.Decode(&dest, hook)) {
+		assert.Equal(t, net.ParseIP("10.0.0.1"), dest.Addr)
+		assert.Equal(t, net.ParseIP("::1"), *dest.Ptr)
+		assert.Equal(t, []net.IP{net.ParseIP("1.2.3.4")}, dest.List)
+		assert.Nil(t, dest.Empty)
+	}
+	assert.EqualError(t, mustParse(t, `{"addr": "x"}`).Decode(&dest, hook), "invalid IP address: x")
+
+	var i int
+	wrong := jtree.OpDecodeHook(func(jtree.Node, reflect.Type) (interface{}, bool, error) { return "x", true, nil })
+	assert.EqualError(t, newNumNode("1").Decode(&i, wrong), "jtree: hook returned string for int")
+}
+
+func TestStrictTypes(t *testing.T) {
+	var (
+		b bool
+		s string
+		i int
+	)
+	assert.NoError(t, newNumNode("1").Decode(&b))
+	assert.EqualError(t, newNumNode("1").Decode(&b, jtree.OpStrictTypes), "jtree: can't convert number to bool")
+	assert.EqualError(t, newNumNode("1").Decode(&s, jtree.OpStrictTypes), "jtree: can't convert number to string")
+	assert.EqualError(t, jtree.Bool(true).Decode(&i, jtree.OpStrictTypes), "jtree: can't convert boolean to int")
+	assert.EqualError(t, jtree.Bool(true).Decode(&s, jtree.OpStrictTypes), "jtree: can't convert boolean to string")
+
+	var dest struct {
+		N int     `json:"n,string"`
+		F float64 `json:"f"`
+		B []bool  `json:"b"`
+	}
+	assert.NoError(t, mustParse(t, `{"n": "1", "f": 2, "b": [true]}`).Decode(&dest, jtree.OpStrictTypes))
+	assert.EqualError(t, mustParse(t, `{"b": [1]}`).Decode(&dest, jtree.OpStrictTypes), "jtree: can't convert number to bool")
+}
+
+func TestNumericRange(t *testing.T) {
+	var (
+		i8  int8
+		i64 int64
+		u8  uint8
+		u   uint
+		f32 float32
+		f64 float64
+	)
+	assert.EqualError(t, newNumNode("1180591620717411303424").Decode(&i8), "jtree: number 1.1805916207174113034e+21 overflows int8")
+	assert.EqualError(t, newNumNode("128").Decode(&i8), "jtree: number 128 overflows int8")
+	assert.NoError(t, newNumNode("-128").Decode(&i8))
+	assert.Equal(t, int8(-128), i8)
+	assert.EqualError(t, newNumNode("9223372036854775808").Decode(&i64), "jtree: number 9.223372036854775808e+18 overflows int64")
+	assert.EqualError(t, newNumNode("256").Decode(&u8), "jtree: number 256 overflows uint8")
+	assert.EqualError(t, newNumNode("-1").Decode(&u), "jtree: number -1 overflows uint")
+	assert.NoError(t, newNumNode("-0.5").Decode(&u))
+	assert.EqualError(t, newNumNode("1e39").Decode(&f32), "jtree: number 1e+39 overflows float32")
+	assert.EqualError(t, newNumNode("1e309").Decode(&f64), "jtree: number 1e+309 overflows float64")
+	assert.NoError(t, newNumNode("1e38").Decode(&f32))
+	assert.Error(t, jtree.String("300").Decode(&u8, jtree.OpString))
+}
+
+func TestDisallowNull(t *testing.T) {
+	var dest struct {
+		N int            `json:"n"`
+		P *int           `json:"p"`
+		M map[string]int `json:"m"`
+		S string         `json:"s,notnull"`
+		Q *string        `json:"q,notnull"`
+	}
+	assert.NoError(t, mustParse(t, `{"n": null, "p": null, "m": null}`).Decode(&dest))
+	assert.EqualError(t, mustParse(t, `{"n": null}`).Decode(&dest, jtree.OpDisallowNull), "jtree: null is not allowed: int")
+	assert.NoError(t, mustParse(t, `{"p": null, "m": null}`).Decode(&dest, jtree.OpDisallowNull))
+	assert.EqualError(t, mustParse(t, `{"s": null}`).Decode(&dest), "jtree: null is not allowed: string")
+	assert.EqualError(t, mustParse(t, `{"q": null}`).Decode(&dest), "jtree: null is not allowed: *string")
+
+	var list []int
+	assert.EqualError(t, mustParse(t, `[1, null]`).Decode(&list, jtree.OpDisallowNull), "jtree: null is not allowed: int")
+}
+
+func TestMergeExisting(t *testing.T) {
+	type config struct {
+		Name   string         `json:"name"`
+		Limits map[string]int `json:"limits"`
+		Tags   []string       `json:"tags"`
+	}
+	src := mustParse(t, `{"limits": {"cpu": 2}, "tags": ["a"]}`)
+
+	dest := config{Name: "default", Limits: map[string]int{"cpu": 1, "mem": 512}}
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, config{Name: "default", Limits: map[string]int{"cpu": 2}, Tags: []string{"a"}}, dest)
+	}
+
+	tags := make([]string, 2, 4)
+	dest = config{Name: "default", Limits: map[string]int{"cpu": 1, "mem": 512}, Tags: tags}
+	if assert.NoError(t, src.Decode(&dest, jtree.OpMergeExisting)) {
+		assert.Equal(t, config{Name: "default", Limits: map[string]int{"cpu": 2, "mem": 512}, Tags: []string{"a"}}, dest)
+		assert.Equal(t, "a", tags[0])
+	}
+}
+
+type pairKey struct {
+	a, b string
+}
+
+func (k *pairKey) UnmarshalText(text []byte) error {
+	p := strings.SplitN(string(text), ":", 2)
+	if len(p) != 2 {
+		return errors.New("colon expected")
+	}
+	k.a, k.b = p[0], p[1]
+	return nil
+}
+
+func TestMapKeys(t *testing.T) {
+	var ints map[int8]string
+	if assert.NoError(t, mustParse(t, `{"1": "a", "-2": "b"}`).Decode(&ints)) {
+		assert.Equal(t, map[int8]string{1: "a", -2: "b"}, ints)
+	}
+	var pairs map[pairKey]int
+	if assert.NoError(t, mustParse(t, `{"x:y": 1}`).Decode(&pairs)) {
+		assert.Equal(t, map[pairKey]int{{"x", "y"}: 1}, pairs)
+	}
+	var floats map[float64]bool
+	if assert.NoError(t, mustParse(t, `{"1.5": true}`).Decode(&floats)) {
+		assert.Equal(t, map[float64]bool{1.5: true}, floats)
+	}
+	var any map[interface{}]int
+	if assert.NoError(t, mustParse(t, `{"k": 1}`).Decode(&any)) {
+		assert.Equal(t, map[interface{}]int{"k": 1}, any)
+	}
+
+	var u8 map[uint8]int
+	assert.EqualError(t, mustParse(t, `{"256": 1}`).Decode(&u8), `jtree: strconv.ParseUint: parsing "256": value out of range`)
+	assert.EqualError(t, mustParse(t, `{"x": 1}`).Decode(&pairs), "jtree: colon expected")
+	var structs map[struct{ A int }]int
+	assert.EqualError(t, mustParse(t, `{"x": 1}`).Decode(&structs), "jtree: unsupported map key type: struct { A int }")
+}
+
+type shape interface {
+	Area() float64
+}
+
+type circle struct {
+	R float64 `json:"r"`
+}
+
+func (c *circle) Area() float64 { return 3 * c.R * c.R }
+
+type square struct {
+	Side float64 `json:"side"`
+}
+
+func (s square) Area() float64 { return s.Side * s.Side }
+
+func TestUnionTag(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	reg.RegisterVariant((*shape)(nil), "circle", (*circle)(nil))
+	reg.RegisterVariant((*shape)(nil), "square", square{})
+	assert.Panics(t, func() { reg.RegisterVariant((*shape)(nil), "square", square{}) })
+	assert.Panics(t, func() { reg.RegisterVariant((*shape)(nil), "int", 1) })
+
+	type msg struct {
+		Kind  string `json:"kind"`
+		Shape shape  `json:"shape,union=kind"`
+	}
+	var list []msg
+	src := mustParse(t, `[{"kind": "circle", "shape": {"r": 1}}, {"shape": {"side": 2}, "kind": "square"}, {"kind": "circle", "shape": null}]`)
+	if assert.NoError(t, src.Decode(&list, jtree.OpTypes(reg))) {
+		assert.Equal(t, []msg{
+			{Kind: "circle", Shape: &circle{R: 1}},
+			{Kind: "square", Shape: square{Side: 2}},
+			{Kind: "circle"},
+		}, list)
+	}
+
+	var m msg
+	assert.EqualError(t, mustParse(t, `{"kind": "oval", "shape": {}}`).Decode(&m, jtree.OpTypes(reg)), "jtree: unknown variant 'oval': jtree_test.shape")
+	assert.EqualError(t, mustParse(t, `{"shape": {}}`).Decode(&m, jtree.OpTypes(reg)), "jtree: string discriminator 'kind' expected: jtree_test.shape")
+}
+
+func TestKeyOptions(t *testing.T) {
+	var m map[string]int
+	if assert.NoError(t, mustParse(t, `{"6869": 1}`).Decode(&m, jtree.OpKey(jtree.OpEncoding(jtree.Hex)))) {
+		assert.Equal(t, map[string]int{"hi": 1}, m)
+	}
+	var list []map[string]int
+	if assert.NoError(t, mustParse(t, `[{"6869": 1}]`).Decode(&list, jtree.OpElem(jtree.OpKey(jtree.OpEncoding(jtree.Hex))))) {
+		assert.Equal(t, []map[string]int{{"hi": 1}}, list)
+	}
+	var nested map[string]map[string]int
+	if assert.NoError(t, mustParse(t, `{"6869": {"6869": 1}}`).Decode(&nested, jtree.OpKey(jtree.OpEncoding(jtree.Hex)))) {
+		assert.Equal(t, map[string]map[string]int{"hi": {"6869": 1}}, nested)
+	}
+	assert.Error(t, mustParse(t, `{"xx": 1}`).Decode(&m, jtree.OpKey(jtree.OpEncoding(jtree.Hex))))
+
+	// key options without an encoding keep the default key conversions
+	var ints map[int]string
+	if assert.NoError(t, mustParse(t, `{"1": "a", "-2": "b"}`).Decode(&ints, jtree.OpKey())) {
+		assert.Equal(t, map[int]string{1: "a", -2: "b"}, ints)
+	}
+}
+
+func TestInlineField(t *testing.T) {
+	type base struct {
+		Ext map[string]jtree.Node `json:",inline"`
+	}
+	type msg struct {
+		base
+		ID    string                 `json:"id"`
+		Other map[string]interface{} `json:"other"`
+	}
+	var dest msg
+	src := mustParse(t, `{"id": "1", "x-trace": "abc", "other": {"a": 1}, "x-n": 2}`)
+	if assert.NoError(t, src.Decode(&dest, jtree.OpDisallowUnknownFields, jtree.OpRequireFields)) {
+		assert.Equal(t, "1", dest.ID)
+		assert.Equal(t, map[string]interface{}{"a": float64(1)}, dest.Other)
+		assert.Equal(t, map[string]jtree.Node{"x-trace": jtree.String("abc"), "x-n": newNumNode("2")}, dest.Ext)
+	}
+
+	var ifaces struct {
+		Rest map[string]interface{} `json:",inline"`
+	}
+	if assert.NoError(t, src.Decode(&ifaces)) {
+		assert.Equal(t, map[string]interface{}{"id": "1", "x-trace": "abc", "other": map[string]interface{}{"a": float64(1)}, "x-n": float64(2)}, ifaces.Rest)
+	}
+}
+
+func TestSquashField(t *testing.T) {
+	type address struct {
+		Street string `json:"street"`
+		City   string `json:"city"`
+	}
+	type meta struct {
+		Version int `json:"version"`
+	}
+	type person struct {
+		Name    string   `json:"name"`
+		Address address  `json:",squash"`
+		Meta    *meta    `json:"meta,squash"`
+		Skipped *address `json:"skipped"`
+	}
+	var dest person
+	src := mustParse(t, `{"name": "x", "street": "Main", "city": "Springfield", "version": 2}`)
+	if assert.NoError(t, src.Decode(&dest, jtree.OpDisallowUnknownFields)) {
+		assert.Equal(t, person{Name: "x", Address: address{Street: "Main", City: "Springfield"}, Meta: &meta{Version: 2}}, dest)
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	src := mustParse(t, `[1, 2, 3]`)
+	var dest []int
+	if assert.NoError(t, jtree.DecodeContext(context.Background(), src, &dest)) {
+		assert.Equal(t, []int{1, 2, 3}, dest)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, jtree.DecodeContext(ctx, src, &dest), context.Canceled)
+
+	dec := jtree.NewDecoder(strings.NewReader(`{"a": 1} {"a": 2}`))
+	var v map[string]int
+	if assert.NoError(t, dec.DecodeContext(context.Background(), &v)) {
+		assert.Equal(t, map[string]int{"a": 1}, v)
+	}
+	assert.ErrorIs(t, dec.DecodeContext(ctx, &v), context.Canceled)
+}
+
+func TestMaxDecodeDepth(t *testing.T) {
+	type list struct {
+		Next *list `json:"next"`
+	}
+	src := mustParse(t, `{"next": {"next": {"next": {"next": null}}}}`)
+	var dest list
+	assert.NoError(t, src.Decode(&dest, jtree.OpMaxDecodeDepth(4)))
+	assert.EqualError(t, src.Decode(&dest, jtree.OpMaxDecodeDepth(3)), "jtree: maximum decode depth of 3 exceeded")
+	assert.NoError(t, src.Decode(&dest, jtree.OpMaxDecodeDepth(-1)))
+
+	deep := mustParse(t, strings.Repeat(`{"next": `, jtree.DefaultMaxDecodeDepth+1)+"null"+strings.Repeat("}", jtree.DefaultMaxDecodeDepth+1))
+	assert.Error(t, deep.Decode(&dest))
+}
+
+func TestCollectErrors(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name,required"`
+	}
+	type doc struct {
+		Count int            `json:"count"`
+		Items []item         `json:"items"`
+		Tags  map[string]int `json:"tags"`
+	}
+	src := mustParse(t, `{"count": "x", "items": [{"id": 1, "name": "a"}, {"id": true}], "tags": {"a/b": 1, "c": []}}`)
+	var dest doc
+	err := src.Decode(&dest, jtree.OpCollectErrors, jtree.OpStrictTypes)
+	if assert.Error(t, err) {
+		var paths []string
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var de *jtree.DecodeError
+			if assert.ErrorAs(t, e, &de) {
+				paths = append(paths, de.Pointer())
+			}
+		}
+		assert.Equal(t, []string{"/count", "/items/1/id", "/items/1", "/tags/c"}, paths)
+	}
+	assert.Equal(t, "a", dest.Items[0].Name)
+	assert.Equal(t, map[string]int{"a/b": 1}, dest.Tags)
+
+	// the first error is returned as is without the option
+	err = src.Decode(&dest, jtree.OpStrictTypes)
+	var de *jtree.DecodeError
+	assert.False(t, errors.As(err, &de))
+}
+
+func TestDecodeValue(t *testing.T) {
+	type s struct {
+		A int `json:"a"`
+		b int
+	}
+	var dest s
+	out := reflect.ValueOf(&dest).Elem()
+	assert.NoError(t, jtree.DecodeValue(mustParse(t, `{"a": 1}`), out))
+	assert.NoError(t, jtree.DecodeValue(mustParse(t, `2`), out.Field(0)))
+	assert.Equal(t, 2, dest.A)
+	assert.EqualError(t, jtree.DecodeValue(mustParse(t, `3`), out.Field(1)), "jtree: value is not settable: int")
+	assert.EqualError(t, jtree.DecodeValue(mustParse(t, `3`), reflect.ValueOf(1)), "jtree: value is not settable: int")
+
+	var p *int
+	assert.NoError(t, jtree.DecodeValue(mustParse(t, `4`), reflect.ValueOf(&p).Elem()))
+	assert.Equal(t, 4, *p)
+	assert.NoError(t, jtree.DecodeValue(mustParse(t, `5`), reflect.ValueOf(p)))
+	assert.Equal(t, 5, *p)
+}
+
+func TestFieldDecoder(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	reg.RegisterFieldDecoder("money", func(node jtree.Node, out reflect.Value, ctx *jtree.Context) error {
+		var s string
+		if err := node.Decode(&s, jtree.OpCtx(ctx)); err != nil {
+			return err
+		}
+		var units, cents int64
+		if _, err := fmt.Sscanf(s, "%d.%d", &units, &cents); err != nil {
+			return err
+		}
+		out.SetInt(units*100 + cents)
+		return nil
+	})
+	type order struct {
+		Price int64 `json:"price,decoder=money"`
+		Tax   int64 `json:"tax,decoder=unknown"`
+	}
+	var dest order
+	if assert.NoError(t, mustParse(t, `{"price": "12.34"}`).Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, int64(1234), dest.Price)
+	}
+	assert.EqualError(t, mustParse(t, `{"tax": "1.00"}`).Decode(&dest, jtree.OpTypes(reg)), "jtree: unknown field decoder 'unknown': jtree_test.order")
+}
+
+type celsius float64
+
+func TestConcreteTypeConstructor(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	reg.RegisterType(func(n jtree.Node, ctx *jtree.Context) (celsius, error) {
+		var s string
+		if err := n.Decode(&s, jtree.OpCtx(ctx)); err != nil {
+			return 0, err
+		}
+		var v float64
+		_, err := fmt.Sscanf(s, "%gC", &v)
+		return celsius(v), err
+	})
+	var dest struct {
+		Temp  celsius   `json:"temp"`
+		Ptr   *celsius  `json:"ptr"`
+		Slice []celsius `json:"slice"`
+	}
+	if assert.NoError(t, mustParse(t, `{"temp": "21.5C", "ptr": "-3C", "slice": ["1C", "2C"]}`).Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, celsius(21.5), dest.Temp)
+		assert.Equal(t, celsius(-3), *dest.Ptr)
+		assert.Equal(t, []celsius{1, 2}, dest.Slice)
+	}
+	assert.Panics(t, func() {
+		reg.RegisterType(func(n jtree.Node, ctx *jtree.Context) (*celsius, error) { return nil, nil })
+	})
+}
+
+func TestRegisterTypeFor(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	jtree.RegisterTypeFor(reg, func(n jtree.Node, ctx *jtree.Context) (fmt.Stringer, error) {
+		var s string
+		err := n.Decode(&s, jtree.OpCtx(ctx))
+		return jtree.Number(s), err
+	})
+	jtree.RegisterTypeFor(reg, func(n jtree.Node, ctx *jtree.Context) (celsius, error) {
+		return 36.6, nil
+	})
+	var dest struct {
+		S fmt.Stringer `json:"s"`
+		C celsius      `json:"c"`
+	}
+	if assert.NoError(t, mustParse(t, `{"s": "123", "c": null}`).Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, jtree.Number("123"), dest.S)
+		assert.Equal(t, celsius(0), dest.C)
+	}
+	if assert.NoError(t, mustParse(t, `{"c": {}}`).Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, celsius(36.6), dest.C)
+	}
+	assert.Panics(t, func() {
+		jtree.RegisterTypeFor(reg, func(n jtree.Node, ctx *jtree.Context) (celsius, error) { return 0, nil })
+	})
+}
+
+func TestEncodedBy(t *testing.T) {
+	type blob struct {
+		Encoding string `json:"encoding"`
+		Data     []byte `json:"data,encodedby=encoding"`
+	}
+	var dest blob
+	if assert.NoError(t, mustParse(t, `{"data": "0102ff", "encoding": "hex"}`).Decode(&dest)) {
+		assert.Equal(t, []byte{1, 2, 0xff}, dest.Data)
+	}
+	if assert.NoError(t, mustParse(t, `{"encoding": "base64", "data": "AQL/"}`).Decode(&dest)) {
+		assert.Equal(t, []byte{1, 2, 0xff}, dest.Data)
+	}
+	// the default encoding is used without the discriminator
+	if assert.NoError(t, mustParse(t, `{"data": "AQL/"}`).Decode(&dest)) {
+		assert.Equal(t, []byte{1, 2, 0xff}, dest.Data)
+	}
+	assert.EqualError(t, mustParse(t, `{"encoding": "rot13", "data": "AQL/"}`).Decode(&dest), "jtree: unknown encoding 'rot13': jtree_test.blob")
+	assert.EqualError(t, mustParse(t, `{"encoding": 1, "data": "AQL/"}`).Decode(&dest), "jtree: string encoding name 'encoding' expected: jtree_test.blob")
+}
+
+func TestRegisterUnion(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	jtree.RegisterUnion(reg, "type", map[string]func() shape{
+		"circle": func() shape { return new(circle) },
+		"square": func() shape { return square{} },
+	})
+	var dest []shape
+	src := mustParse(t, `[{"type": "circle", "r": 1}, {"type": "square", "side": 2}]`)
+	if assert.NoError(t, src.Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, []shape{&circle{R: 1}, square{Side: 2}}, dest)
+	}
+	assert.EqualError(t, mustParse(t, `[{"type": "oval"}]`).Decode(&dest, jtree.OpTypes(reg)), "jtree: unknown variant 'oval': jtree_test.shape")
+	assert.EqualError(t, mustParse(t, `[{}]`).Decode(&dest, jtree.OpTypes(reg)), "jtree: string discriminator 'type' expected: jtree_test.shape")
+
+	reg = jtree.NewTypeRegistry()
+	jtree.RegisterUnion(reg, "type", map[string]func() shape{
+		"none":   func() shape { return nil },
+		"circle": func() shape { return (*circle)(nil) },
+	})
+	assert.EqualError(t, mustParse(t, `[{"type": "none"}]`).Decode(&dest, jtree.OpTypes(reg)), "jtree: variant 'none' constructor returned nil: jtree_test.shape")
+	assert.EqualError(t, mustParse(t, `[{"type": "circle"}]`).Decode(&dest, jtree.OpTypes(reg)), "jtree: variant 'circle' constructor returned nil: jtree_test.shape")
+	assert.PanicsWithValue(t, `jtree: nil variant "circle" constructor: jtree_test.shape`, func() {
+		jtree.RegisterUnion(jtree.NewTypeRegistry(), "type", map[string]func() shape{"circle": nil})
+	})
+}
+
+func TestCoercion(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	reg.RegisterCoercion(jtree.CoerceNumericStrings)
+	reg.RegisterCoercion(jtree.CoerceBoolStrings)
+	type rec struct {
+		ID      int64   `json:"id"`
+		Ratio   float64 `json:"ratio"`
+		Enabled bool    `json:"enabled"`
+		Name    string  `json:"name"`
+	}
+	src := mustParse(t, `{"id": "42", "ratio": "0.5", "enabled": "true", "name": "x"}`)
+	var dest rec
+	assert.Error(t, src.Decode(&dest))
+	if assert.NoError(t, src.Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, rec{ID: 42, Ratio: 0.5, Enabled: true, Name: "x"}, dest)
+	}
+	assert.Error(t, mustParse(t, `{"id": "x"}`).Decode(&dest, jtree.OpTypes(reg)))
+}
+
+type hexID []byte
+
+func TestConstructorOptions(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	reg.RegisterType(func(n jtree.Node, ctx *jtree.Context, op []jtree.Option) (hexID, error) {
+		var b []byte
+		err := n.Decode(&b, op...)
+		return hexID(b), err
+	})
+	var dest struct {
+		Default hexID `json:"default"`
+		Hex     hexID `json:"hex,hex"`
+	}
+	if assert.NoError(t, mustParse(t, `{"default": "AQI=", "hex": "0102"}`).Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, hexID{1, 2}, dest.Default)
+		assert.Equal(t, hexID{1, 2}, dest.Hex)
+	}
+	assert.Panics(t, func() {
+		reg.RegisterType(func(n jtree.Node, ctx *jtree.Context, op ...jtree.Option) (celsius, error) { return 0, nil })
+	})
+}
+
+func TestFallback(t *testing.T) {
+	parent := jtree.NewTypeRegistry()
+	parent.SetFallback(func(t reflect.Type, node jtree.Node, ctx *jtree.Context) (interface{}, bool, error) {
+		if t == reflect.TypeOf((*shape)(nil)).Elem() {
+			return square{Side: 1}, true, nil
+		}
+		return nil, false, nil
+	})
+	reg := jtree.NewChildTypeRegistry(parent)
+	reg.SetFallback(func(t reflect.Type, node jtree.Node, ctx *jtree.Context) (interface{}, bool, error) {
+		if t == reflect.TypeOf((*fmt.Stringer)(nil)).Elem() {
+			var s string
+			err := node.Decode(&s, jtree.OpCtx(ctx))
+			return jtree.Number(s), true, err
+		}
+		if t == reflect.TypeOf((*error)(nil)).Elem() {
+			return 1, true, nil
+		}
+		return nil, false, nil
+	})
+	var dest struct {
+		S   fmt.Stringer `json:"s"`
+		Sh  shape        `json:"sh"`
+		Any interface{}  `json:"any"`
+		Err error        `json:"err"`
+	}
+	if assert.NoError(t, mustParse(t, `{"s": "1", "sh": {}, "any": true}`).Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, jtree.Number("1"), dest.S)
+		assert.Equal(t, square{Side: 1}, dest.Sh)
+		assert.Equal(t, true, dest.Any)
+	}
+	assert.EqualError(t, mustParse(t, `{"err": {}}`).Decode(&dest, jtree.OpTypes(reg)), "jtree: fallback returned int for error")
+}
+
+type money struct {
+	Cents int64
+	Valid bool
+}
+
+func (m *money) DecodeJSON(node jtree.Node) error {
+	m.Valid = true
+	return node.Decode(&m.Cents)
+}
+
+func TestNullHandler(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	jtree.RegisterNullFor(reg, func(*jtree.Context) (money, error) { return money{Cents: -1}, nil })
+	var dest struct {
+		A money   `json:"a"`
+		B money   `json:"b"`
+		P *money  `json:"p"`
+		L []money `json:"l"`
+	}
+	src := mustParse(t, `{"a": 100, "b": null, "p": null, "l": [null, 1]}`)
+	if assert.NoError(t, src.Decode(&dest, jtree.OpTypes(reg))) {
+		assert.Equal(t, money{Cents: 100, Valid: true}, dest.A)
+		assert.Equal(t, money{Cents: -1}, dest.B)
+		assert.Nil(t, dest.P)
+		assert.Equal(t, []money{{Cents: -1}, {Cents: 1, Valid: true}}, dest.L)
+	}
+	assert.Panics(t, func() {
+		jtree.RegisterNullFor(reg, func(*jtree.Context) (money, error) { return money{}, nil })
+	})
+}
+
+func TestNewContext(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	jtree.RegisterTypeFor(reg, func(n jtree.Node, ctx *jtree.Context) (celsius, error) { return 7, nil })
+	ctx := jtree.NewContext(jtree.WithTypes(reg), jtree.WithStrictTypes, jtree.WithOptions(jtree.OpDisallowUnknownFields))
+
+	type rec struct {
+		C celsius `json:"c"`
+		S string  `json:"s"`
+	}
+	var dest rec
+	if assert.NoError(t, mustParse(t, `{"c": 0, "s": "x"}`).Decode(&dest, jtree.OpCtx(ctx))) {
+		assert.Equal(t, rec{C: 7, S: "x"}, dest)
+	}
+	assert.Error(t, mustParse(t, `{"s": 1}`).Decode(&dest, jtree.OpCtx(ctx)))
+	assert.Error(t, mustParse(t, `{"x": 1}`).Decode(&dest, jtree.OpCtx(ctx)))
+
+	// the shared context is not bound to the cancellation context
+	cctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, jtree.DecodeContext(cctx, mustParse(t, `{}`), &dest, jtree.OpCtx(ctx)))
+	assert.NoError(t, mustParse(t, `{}`).Decode(&dest, jtree.OpCtx(ctx)))
+
+	// global options following OpCtx don't modify the shared context
+	shared := jtree.NewContext()
+	assert.EqualError(t, mustParse(t, `{"x": 1}`).Decode(&dest, jtree.OpCtx(shared), jtree.OpDisallowUnknownFields), "jtree: undefined field 'x': jtree_test.rec")
+	assert.EqualError(t, mustParse(t, `{"s": 1}`).Decode(&dest, jtree.OpCtx(shared), jtree.OpStrictTypes), "jtree: can't convert number to string")
+	if assert.NoError(t, mustParse(t, `{"x": 1, "s": 1}`).Decode(&dest, jtree.OpCtx(shared))) {
+		assert.Equal(t, "1", dest.S)
+	}
+}
+
+type tenantKey struct{}
+
+type tenantRecord struct {
+	Tenant string
+	Name   string
+}
+
+func (r *tenantRecord) DecodeJSONContext(node jtree.Node, ctx *jtree.Context) error {
+	r.Tenant, _ = ctx.Value(tenantKey{}).(string)
+	return node.Decode(&r.Name, jtree.OpCtx(ctx))
+}
+
+func TestContextValues(t *testing.T) {
+	base := jtree.NewContext(jtree.WithValue("flag", true))
+	ctx := base.WithValue(tenantKey{}, "acme")
+	assert.Nil(t, base.Value(tenantKey{}))
+	assert.Equal(t, true, ctx.Value("flag"))
+
+	var dest []tenantRecord
+	if assert.NoError(t, mustParse(t, `["a", "b"]`).Decode(&dest, jtree.OpCtx(ctx))) {
+		assert.Equal(t, []tenantRecord{{Tenant: "acme", Name: "a"}, {Tenant: "acme", Name: "b"}}, dest)
+	}
+}
+
+func TestDecodeIntBoundaries(t *testing.T) {
+	var i int64
+	assert.NoError(t, mustParse(t, `9223372036854775807`).Decode(&i))
+	assert.Equal(t, int64(math.MaxInt64), i)
+	assert.NoError(t, mustParse(t, `-9223372036854775808`).Decode(&i))
+	assert.Equal(t, int64(math.MinInt64), i)
+	assert.Error(t, mustParse(t, `9223372036854775808`).Decode(&i))
+	assert.Error(t, mustParse(t, `-9223372036854775809`).Decode(&i))
+	assert.NoError(t, mustParse(t, `-1.5`).Decode(&i))
+	assert.Equal(t, int64(-1), i)
+
+	var u uint64
+	assert.NoError(t, mustParse(t, `18446744073709551615`).Decode(&u))
+	assert.Equal(t, uint64(math.MaxUint64), u)
+	assert.NoError(t, mustParse(t, `-0.5`).Decode(&u))
+	assert.Equal(t, uint64(0), u)
+	assert.Error(t, mustParse(t, `18446744073709551616`).Decode(&u))
+	assert.Error(t, mustParse(t, `-1`).Decode(&u))
+}
+
+func TestPrimitiveSlices(t *testing.T) {
+	var s []string
+	if assert.NoError(t, mustParse(t, `["a", "b", null]`).Decode(&s)) {
+		assert.Equal(t, []string{"a", "b", ""}, s)
+	}
+	var i []int
+	if assert.NoError(t, mustParse(t, `[1, -2, 3.7]`).Decode(&i)) {
+		assert.Equal(t, []int{1, -2, 3}, i)
+	}
+	assert.EqualError(t, mustParse(t, `[1, "x"]`).Decode(&i), "jtree: can't convert string to int")
+	var i64 []int64
+	assert.EqualError(t, mustParse(t, `[9223372036854775808]`).Decode(&i64), "jtree: number 9.223372036854775808e+18 overflows int64")
+	var f []float64
+	if assert.NoError(t, mustParse(t, `[1.5, 2]`).Decode(&f)) {
+		assert.Equal(t, []float64{1.5, 2}, f)
+	}
+	var b []bool
+	if assert.NoError(t, mustParse(t, `[true, false]`).Decode(&b)) {
+		assert.Equal(t, []bool{true, false}, b)
+	}
+
+	// element options disable the fast path
+	if assert.NoError(t, mustParse(t, `["1", "2"]`).Decode(&i, jtree.OpElem(jtree.OpString))) {
+		assert.Equal(t, []int{1, 2}, i)
+	}
+}
+
+// upperNode is the user defined node decoding as an upper case string
+type upperNode struct{ jtree.String }
+
+func (n upperNode) Decode(v interface{}, op ...jtree.Option) error {
+	return jtree.String(strings.ToUpper(string(n.String))).Decode(v, op...)
+}
+
+func TestUserNodeMembers(t *testing.T) {
+	src := jtree.Object{
+		{Key: "a", Value: upperNode{"x"}},
+		{Key: "b", Value: jtree.Array{upperNode{"y"}, jtree.String("z")}},
+	}
+	var dest struct {
+		A string            `json:"a"`
+		B []string          `json:"b"`
+		M map[string]string `json:"-"`
+	}
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, "X", dest.A)
+		assert.Equal(t, []string{"Y", "z"}, dest.B)
+	}
+	if assert.NoError(t, src[:1].Decode(&dest.M)) {
+		assert.Equal(t, map[string]string{"a": "X"}, dest.M)
+	}
+}
+
+func TestLazyPointers(t *testing.T) {
+	type Inner struct {
+		A int `json:"a"`
+	}
+	var dest struct {
+		*Inner
+		P **Inner `json:"p"`
+	}
+	assert.Error(t, mustParse(t, `{"p": {"a": "x"}}`).Decode(&dest))
+	assert.Nil(t, dest.P)
+	assert.Error(t, mustParse(t, `{"a": "x"}`).Decode(&dest))
+	assert.Nil(t, dest.Inner)
+
+	if assert.NoError(t, mustParse(t, `{"a": 1, "p": {"a": 2}}`).Decode(&dest)) {
+		assert.Equal(t, 1, dest.A)
+		assert.Equal(t, 2, (*dest.P).A)
+	}
+
+	// partial results are kept when errors are collected
+	var partial struct {
+		P *struct {
+			A int `json:"a"`
+			B int `json:"b"`
+		} `json:"p"`
+	}
+	assert.Error(t, mustParse(t, `{"p": {"a": 1, "b": "x"}}`).Decode(&partial, jtree.OpCollectErrors))
+	if assert.NotNil(t, partial.P) {
+		assert.Equal(t, 1, partial.P.A)
+	}
+}
+
+func TestNumberToString(t *testing.T) {
+	src := []struct {
+		num    *jtree.Num
+		expect string
+	}{
+		{newNumNode("1000000"), "1000000"},
+		{newNumNode("-42"), "-42"},
+		{newNumNode("1180591620717411303424"), "1180591620717411303424"},
+		{newNumNode("1.5"), "1.5"},
+		{newNumNode("0.1"), "0.1"},
+		{newNumNode("1.5e-300"), "1.5e-300"},
+		{(*jtree.Num)(big.NewFloat(0.1)), "0.1"},
+	}
+	for _, s := range src {
+		var dest string
+		if assert.NoError(t, s.num.Decode(&dest)) {
+			assert.Equal(t, s.expect, dest)
+		}
+	}
+}
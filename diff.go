@@ -0,0 +1,107 @@
+package jtree
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Diff returns JSON Patch (RFC 6902) transforming a into b as an array of operation objects.
+// Objects are compared by keys regardless of their order, arrays are compared using the longest common subsequence
+func Diff(a, b Node) Array {
+	d := differ{patch: make(Array, 0)}
+	d.diff(a, b, "")
+	return d.patch
+}
+
+type differ struct {
+	patch Array
+}
+
+func (d *differ) op(op, path string, value Node) {
+	o := Object{{Key: "op", Value: String(op)}, {Key: "path", Value: String(path)}}
+	if value != nil {
+		o = append(o, &Field{Key: "value", Value: value})
+	}
+	d.patch = append(d.patch, o)
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func (d *differ) diff(a, b Node, path string) {
+	switch x := a.(type) {
+	case Object:
+		if y, ok := b.(Object); ok {
+			d.diffObject(x, y, path)
+			return
+		}
+	case Array:
+		if y, ok := b.(Array); ok {
+			d.diffArray(x, y, path)
+			return
+		}
+	}
+	if !equal(a, b) {
+		d.op("replace", path, b)
+	}
+}
+
+func (d *differ) diffObject(a, b Object, path string) {
+	ai, bi := a.Index(), b.Index()
+	for _, f := range a {
+		if _, ok := bi.Get(f.Key); !ok {
+			d.op("remove", path+"/"+pointerEscaper.Replace(f.Key), nil)
+		}
+	}
+	for _, f := range b {
+		p := path + "/" + pointerEscaper.Replace(f.Key)
+		if v, ok := ai.Get(f.Key); ok {
+			d.diff(v, f.Value, p)
+		} else {
+			d.op("add", p, f.Value)
+		}
+	}
+}
+
+func (d *differ) diffArray(a, b Array, path string) {
+	n, m := len(a), len(b)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j, k := 0, 0, 0 // k is the index in the patched array
+	for i < n && j < m {
+		p := path + "/" + strconv.Itoa(k)
+		switch {
+		case equal(a[i], b[j]):
+			i, j, k = i+1, j+1, k+1
+		case lcs[i+1][j+1] == lcs[i][j]:
+			// neither element is a part of LCS
+			d.diff(a[i], b[j], p)
+			i, j, k = i+1, j+1, k+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			d.op("remove", p, nil)
+			i++
+		default:
+			d.op("add", p, b[j])
+			j, k = j+1, k+1
+		}
+	}
+	for ; i < n; i++ {
+		d.op("remove", path+"/"+strconv.Itoa(k), nil)
+	}
+	for ; j < m; j++ {
+		d.op("add", path+"/-", b[j])
+	}
+}
@@ -0,0 +1,40 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tst := []struct {
+		a, b, expect string
+	}{
+		{`{"a": 1}`, `{"a": 1.0}`, `[]`},
+		{`{"a": 1, "b": 2}`, `{"b": 2, "a": 1}`, `[]`},
+		{`{"a": 1, "b": 2}`, `{"a": 3, "c/d": 4}`, `[
+			{"op": "remove", "path": "/b"},
+			{"op": "replace", "path": "/a", "value": 3},
+			{"op": "add", "path": "/c~1d", "value": 4}
+		]`},
+		{`[1, 2, 3]`, `[0, 1, 3, 4]`, `[
+			{"op": "add", "path": "/0", "value": 0},
+			{"op": "remove", "path": "/2"},
+			{"op": "add", "path": "/-", "value": 4}
+		]`},
+		{`[{"x": 1}, 5]`, `[{"x": 2}, 5]`, `[
+			{"op": "replace", "path": "/0/x", "value": 2}
+		]`},
+		{`[1, 2, 3]`, `[1]`, `[
+			{"op": "remove", "path": "/1"},
+			{"op": "remove", "path": "/1"}
+		]`},
+		{`{"a": [1]}`, `"x"`, `[
+			{"op": "replace", "path": "", "value": "x"}
+		]`},
+	}
+	for _, tt := range tst {
+		assert.Equal(t, mustParse(t, tt.expect), jtree.Diff(mustParse(t, tt.a), mustParse(t, tt.b)), tt.b)
+	}
+}
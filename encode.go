@@ -0,0 +1,104 @@
+package jtree
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+)
+
+// appendNode appends compact JSON text of the node to dst. Raw nodes are copied verbatim, user defined nodes
+// are rendered through their Decode method
+func appendNode(dst []byte, n Node) ([]byte, error) {
+	switch node := n.(type) {
+	case *Num:
+		f := (*big.Float)(node)
+		if f.IsInf() {
+			if f.Signbit() {
+				return append(dst, "-Infinity"...), nil
+			}
+			return append(dst, "Infinity"...), nil
+		}
+		return append(dst, node.text()...), nil
+	case NaN:
+		return append(dst, "NaN"...), nil
+	case String:
+		return appendString(dst, string(node)), nil
+	case Object:
+		var err error
+		dst = append(dst, '{')
+		for i, f := range node {
+			if i != 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendString(dst, f.Key)
+			dst = append(dst, ':')
+			if dst, err = appendNode(dst, f.Value); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, '}'), nil
+	case Array:
+		var err error
+		dst = append(dst, '[')
+		for i, elem := range node {
+			if i != 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = appendNode(dst, elem); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	case Bool:
+		return strconv.AppendBool(dst, bool(node)), nil
+	case Null:
+		return append(dst, "null"...), nil
+	case Raw:
+		return append(dst, node...), nil
+	case nil:
+		return nil, errors.New("jtree: can't encode nil node")
+	default:
+		// user defined nodes are rendered by their own decoders
+		var raw RawMessage
+		if err := n.Decode(&raw); err != nil {
+			return nil, err
+		}
+		return append(dst, raw...), nil
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < 0x20:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, `\ufffd`...)
+		} else {
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
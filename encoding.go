@@ -1,6 +1,7 @@
 package jtree
 
 import (
+	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
 )
@@ -11,29 +12,75 @@ type Encoding interface {
 	Decode([]byte) ([]byte, error)
 }
 
-type base64Encoding struct{}
+type base64Encoding struct {
+	enc *base64.Encoding
+}
+
+func (e base64Encoding) Encode(src []byte) []byte {
+	buf := make([]byte, e.enc.EncodedLen(len(src)))
+	e.enc.Encode(buf, src)
+	return buf
+}
+
+func (e base64Encoding) Decode(src []byte) ([]byte, error) {
+	buf := make([]byte, e.enc.DecodedLen(len(src)))
+	n, err := e.enc.Decode(buf, src)
+	return buf[:n], err
+}
+
+// EncodingFromBase64 wraps the standard library base64 encoding into Encoding
+func EncodingFromBase64(enc *base64.Encoding) Encoding { return base64Encoding{enc} }
+
+type base32Encoding struct {
+	enc *base32.Encoding
+}
 
-func (base64Encoding) Encode(src []byte) []byte {
-	buf := make([]byte, base64.StdEncoding.EncodedLen(len(src)))
-	base64.StdEncoding.Encode(buf, src)
+func (e base32Encoding) Encode(src []byte) []byte {
+	buf := make([]byte, e.enc.EncodedLen(len(src)))
+	e.enc.Encode(buf, src)
 	return buf
 }
 
-func (base64Encoding) Decode(src []byte) ([]byte, error) {
-	buf := make([]byte, base64.StdEncoding.DecodedLen(len(src)))
-	n, err := base64.StdEncoding.Decode(buf, src)
+func (e base32Encoding) Decode(src []byte) ([]byte, error) {
+	buf := make([]byte, e.enc.DecodedLen(len(src)))
+	n, err := e.enc.Decode(buf, src)
 	return buf[:n], err
 }
 
-type hexEncoding struct{}
+// EncodingFromBase32 wraps the standard library base32 encoding into Encoding
+func EncodingFromBase32(enc *base32.Encoding) Encoding { return base32Encoding{enc} }
+
+// HexEncoding is the hex encoding with optional "0x" prefix and uppercase output
+type HexEncoding struct {
+	Prefix bool // emit "0x" prefix and accept input with or without it
+	Upper  bool // emit uppercase digits
+}
 
-func (hexEncoding) Encode(src []byte) []byte {
-	buf := make([]byte, hex.EncodedLen(len(src)))
-	hex.Encode(buf, src)
+// Encode encodes src as hex text
+func (e HexEncoding) Encode(src []byte) []byte {
+	var prefix string
+	if e.Prefix {
+		prefix = "0x"
+	}
+	off := len(prefix)
+	buf := make([]byte, off+hex.EncodedLen(len(src)))
+	copy(buf, prefix)
+	hex.Encode(buf[off:], src)
+	if e.Upper {
+		for i := off; i < len(buf); i++ {
+			if c := buf[i]; c >= 'a' && c <= 'f' {
+				buf[i] = c - ('a' - 'A')
+			}
+		}
+	}
 	return buf
 }
 
-func (hexEncoding) Decode(src []byte) ([]byte, error) {
+// Decode decodes hex text of any case
+func (e HexEncoding) Decode(src []byte) ([]byte, error) {
+	if e.Prefix && len(src) >= 2 && src[0] == '0' && (src[1] == 'x' || src[1] == 'X') {
+		src = src[2:]
+	}
 	buf := make([]byte, hex.DecodedLen(len(src)))
 	n, err := hex.Decode(buf, src)
 	return buf[:n], err
@@ -41,7 +88,21 @@ func (hexEncoding) Decode(src []byte) ([]byte, error) {
 
 var (
 	// Base64 is the standard base64 encoding
-	Base64 Encoding = base64Encoding{}
+	Base64 Encoding = base64Encoding{base64.StdEncoding}
+	// Base64Raw is the standard unpadded base64 encoding
+	Base64Raw Encoding = base64Encoding{base64.RawStdEncoding}
+	// Base64URL is the URL-safe base64 encoding (RFC 4648)
+	Base64URL Encoding = base64Encoding{base64.URLEncoding}
+	// Base64RawURL is the URL-safe unpadded base64 encoding used by JOSE/JWT
+	Base64RawURL Encoding = base64Encoding{base64.RawURLEncoding}
+	// Base32 is the standard base32 encoding (RFC 4648)
+	Base32 Encoding = base32Encoding{base32.StdEncoding}
+	// Base32Hex is the base32 encoding with the extended hex alphabet (RFC 4648)
+	Base32Hex Encoding = base32Encoding{base32.HexEncoding}
+	// Base58 is the base58 encoding using Bitcoin/IPFS alphabet
+	Base58 Encoding = base58Encoding{}
 	// Hex is the hex encoding (([0-9a-fA-F]{2})*)
-	Hex Encoding = hexEncoding{}
+	Hex Encoding = HexEncoding{}
+	// Hex0x is the hex encoding with "0x" prefix used by Ethereum-style APIs
+	Hex0x Encoding = HexEncoding{Prefix: true}
 )
@@ -0,0 +1,228 @@
+package jtree_test
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodings(t *testing.T) {
+	type testCase struct {
+		name    string
+		enc     jtree.Encoding
+		data    []byte
+		encoded string
+	}
+	tests := []testCase{
+		{name: "base64", enc: jtree.Base64, data: []byte{0xfb, 0xff, 0x01}, encoded: "+/8B"},
+		{name: "base64raw", enc: jtree.Base64Raw, data: []byte{0xfb, 0xff}, encoded: "+/8"},
+		{name: "base64url", enc: jtree.Base64URL, data: []byte{0xfb, 0xff}, encoded: "-_8="},
+		{name: "base64rawurl", enc: jtree.Base64RawURL, data: []byte{0xfb, 0xff}, encoded: "-_8"},
+		{name: "base32", enc: jtree.Base32, data: []byte("hi!"), encoded: "NBUSC==="},
+		{name: "base32hex", enc: jtree.Base32Hex, data: []byte("hi!"), encoded: "D1KI2==="},
+		{name: "base58", enc: jtree.Base58, data: []byte("Hello World!"), encoded: "2NEpo7TZRRrLZSi2U"},
+		{name: "base58 zeros", enc: jtree.Base58, data: []byte{0, 0, 0x28, 0x7f, 0xb4, 0xcd}, encoded: "11233QC4"},
+		{name: "base58 empty", enc: jtree.Base58, data: []byte{}, encoded: ""},
+		{name: "hex", enc: jtree.Hex, data: []byte{0xfb, 0xff}, encoded: "fbff"},
+		{name: "hex0x", enc: jtree.Hex0x, data: []byte{0xfb, 0xff}, encoded: "0xfbff"},
+		{name: "hex upper", enc: jtree.HexEncoding{Prefix: true, Upper: true}, data: []byte{0xfb, 0xff}, encoded: "0xFBFF"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.encoded, string(test.enc.Encode(test.data)))
+			var dest []byte
+			if assert.NoError(t, jtree.String(test.encoded).Decode(&dest, jtree.OpEncoding(test.enc))) {
+				assert.Equal(t, test.data, dest)
+			}
+		})
+	}
+}
+
+func TestEncodingTags(t *testing.T) {
+	var dest struct {
+		URL    []byte `json:"url,base64url"`
+		RawURL []byte `json:"rawurl,base64rawurl"`
+		Raw    []byte `json:"raw,base64raw"`
+		B32    []byte `json:"b32,base32"`
+	}
+	src := mustParse(t, `{"url": "-_8=", "rawurl": "-_8", "raw": "+/8", "b32": "NBUSC==="}`)
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, []byte{0xfb, 0xff}, dest.URL)
+		assert.Equal(t, []byte{0xfb, 0xff}, dest.RawURL)
+		assert.Equal(t, []byte{0xfb, 0xff}, dest.Raw)
+		assert.Equal(t, []byte("hi!"), dest.B32)
+	}
+}
+
+func TestBase58Invalid(t *testing.T) {
+	_, err := jtree.Base58.Decode([]byte("12O"))
+	assert.EqualError(t, err, "jtree: illegal base58 data at input byte 2")
+}
+
+func TestHexPrefix(t *testing.T) {
+	for _, src := range []string{"0xfbff", "0XFBFF", "fbff"} {
+		out, err := jtree.Hex0x.Decode([]byte(src))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []byte{0xfb, 0xff}, out)
+		}
+	}
+	_, err := jtree.Hex.Decode([]byte("0xfbff"))
+	assert.Error(t, err)
+}
+
+func TestStdlibEncodingAdapters(t *testing.T) {
+	b64 := jtree.EncodingFromBase64(base64.NewEncoding("ZYXWVUTSRQPONMLKJIHGFEDCBAzyxwvutsrqponmlkjihgfedcba9876543210+/").WithPadding(base64.NoPadding))
+	assert.Equal(t, "qt", string(b64.Encode([]byte{0x8e})))
+	out, err := b64.Decode([]byte("qt"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte{0x8e}, out)
+	}
+
+	b32 := jtree.EncodingFromBase32(base32.StdEncoding.WithPadding(base32.NoPadding))
+	assert.Equal(t, "NBUSC", string(b32.Encode([]byte("hi!"))))
+	out, err = b32.Decode([]byte("NBUSC"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("hi!"), out)
+	}
+}
+
+func TestValidatingEncoding(t *testing.T) {
+	reg := jtree.NewEncodingRegistry()
+	reg.RegisterEncoding("uuid", jtree.PatternEncoding(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)))
+	reg.RegisterEncoding("country", jtree.ValidatingEncoding(func(src []byte) error {
+		if len(src) != 2 || src[0] < 'A' || src[0] > 'Z' || src[1] < 'A' || src[1] > 'Z' {
+			return errors.New("ISO 3166-1 alpha-2 code expected")
+		}
+		return nil
+	}))
+	type user struct {
+		ID      string `json:"id,uuid"`
+		Country string `json:"country,country"`
+		Raw     []byte `json:"raw,country"`
+	}
+	var dest user
+	src := mustParse(t, `{"id": "123e4567-e89b-12d3-a456-426614174000", "country": "DE", "raw": "FR"}`)
+	if assert.NoError(t, src.Decode(&dest, jtree.OpEncodings(reg))) {
+		assert.Equal(t, user{ID: "123e4567-e89b-12d3-a456-426614174000", Country: "DE", Raw: []byte("FR")}, dest)
+	}
+	assert.EqualError(t, mustParse(t, `{"country": "Germany"}`).Decode(&dest, jtree.OpEncodings(reg)), "jtree: ISO 3166-1 alpha-2 code expected")
+	assert.Error(t, mustParse(t, `{"id": "123"}`).Decode(&dest, jtree.OpEncodings(reg)))
+}
+
+func TestEncodeString(t *testing.T) {
+	s, err := jtree.EncodeString("hex0x", []byte{0xfb, 0xff})
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.String("0xfbff"), s)
+	}
+	_, err = jtree.EncodeString("rot13", nil)
+	assert.EqualError(t, err, "jtree: unknown encoding 'rot13'")
+
+	// round trip through the custom encoding
+	reg := jtree.NewChildEncodingRegistry(jtree.DefaultEncodingRegistry())
+	reg.RegisterEncoding("b58", jtree.Base58)
+	s, err = reg.EncodeString("b58", []byte("abc"))
+	if assert.NoError(t, err) {
+		var dest struct {
+			V []byte `json:"v,b58"`
+		}
+		if assert.NoError(t, jtree.Object{{Key: "v", Value: s}}.Decode(&dest, jtree.OpEncodings(reg))) {
+			assert.Equal(t, []byte("abc"), dest.V)
+		}
+	}
+}
+
+func TestNamespacedEncodings(t *testing.T) {
+	parent := jtree.NewEncodingRegistry()
+	parent.RegisterEncoding("acme/bytes", jtree.Hex)
+	parent.RegisterEncoding("acme/b64", jtree.Base64)
+	parent.RegisterEncoding("other/b64", jtree.Base64URL)
+	reg := jtree.NewChildEncodingRegistry(parent)
+	reg.RegisterEncoding("mycorp/bytes", jtree.Base58)
+
+	e, ok := reg.Encoding("acme/bytes")
+	assert.True(t, ok)
+	assert.Equal(t, jtree.Hex, e)
+	// the child's namespaced entry takes precedence
+	e, ok = reg.Encoding("bytes")
+	assert.True(t, ok)
+	assert.Equal(t, jtree.Base58, e)
+	// ambiguous
+	_, ok = reg.Encoding("b64")
+	assert.False(t, ok)
+	// exact short name wins
+	parent.RegisterEncoding("b64", jtree.Base64RawURL)
+	e, ok = reg.Encoding("b64")
+	assert.True(t, ok)
+	assert.Equal(t, jtree.Base64RawURL, e)
+
+	var dest struct {
+		V []byte `json:"v,acme/bytes"`
+	}
+	if assert.NoError(t, mustParse(t, `{"v": "0102"}`).Decode(&dest, jtree.OpEncodings(reg))) {
+		assert.Equal(t, []byte{1, 2}, dest.V)
+	}
+	assert.Error(t, reg.TryRegisterEncoding("acme/", jtree.Hex))
+	assert.Error(t, reg.TryRegisterEncoding("/x", jtree.Hex))
+
+	// removal makes the short name unique again
+	assert.True(t, parent.Unregister("b64"))
+	assert.True(t, parent.Unregister("other/b64"))
+	e, ok = reg.Encoding("b64")
+	assert.True(t, ok)
+	assert.Equal(t, jtree.Base64, e)
+	clone := parent.Clone()
+	assert.True(t, parent.Unregister("acme/b64"))
+	_, ok = parent.Encoding("b64")
+	assert.False(t, ok)
+	e, ok = clone.Encoding("b64")
+	assert.True(t, ok)
+	assert.Equal(t, jtree.Base64, e)
+
+	// field tag keywords can't be taken over
+	assert.EqualError(t, reg.TryRegisterEncoding("acme/omitempty", jtree.Hex), `jtree: reserved encoding name: "acme/omitempty"`)
+	assert.EqualError(t, reg.TryRegisterEncoding("required", jtree.Hex), `jtree: reserved encoding name: "required"`)
+	assert.EqualError(t, reg.TryRegisterEncoding("x/union=a", jtree.Hex), `jtree: reserved encoding name: "x/union=a"`)
+	assert.EqualError(t, reg.TryRegisterEncoding("a,b", jtree.Hex), `jtree: invalid encoding name: "a,b"`)
+}
+
+func TestFieldEncodingPerContext(t *testing.T) {
+	type record struct {
+		V []byte `json:"v,blob"`
+	}
+	hex := jtree.NewEncodingRegistry()
+	hex.RegisterEncoding("blob", jtree.Hex)
+	b64 := jtree.NewEncodingRegistry()
+	b64.RegisterEncoding("blob", jtree.Base64)
+
+	// the decoding plan of the type is cached but encodings are resolved per call
+	var dest record
+	if assert.NoError(t, mustParse(t, `{"v": "6869"}`).Decode(&dest, jtree.OpEncodings(hex))) {
+		assert.Equal(t, []byte("hi"), dest.V)
+	}
+	if assert.NoError(t, mustParse(t, `{"v": "aGk="}`).Decode(&dest, jtree.OpEncodings(b64))) {
+		assert.Equal(t, []byte("hi"), dest.V)
+	}
+
+	// changes of the registry or its parent are picked up
+	parent := jtree.NewEncodingRegistry()
+	child := jtree.NewChildEncodingRegistry(parent)
+	var dest2 struct {
+		V []byte `json:"v,omitempty,acme/blob2,required"`
+	}
+	if assert.NoError(t, mustParse(t, `{"v": "aGk="}`).Decode(&dest2, jtree.OpEncodings(child))) {
+		assert.Equal(t, []byte("hi"), dest2.V)
+	}
+	parent.RegisterEncoding("acme/blob2", jtree.Hex)
+	if assert.NoError(t, mustParse(t, `{"v": "6869"}`).Decode(&dest2, jtree.OpEncodings(child))) {
+		assert.Equal(t, []byte("hi"), dest2.V)
+	}
+	child.RegisterEncoding("acme/blob2", jtree.Base58)
+	if assert.NoError(t, mustParse(t, `{"v": "8wr"}`).Decode(&dest2, jtree.OpEncodings(child))) {
+		assert.Equal(t, []byte("hi"), dest2.V)
+	}
+}
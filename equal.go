@@ -0,0 +1,110 @@
+package jtree
+
+import "math/big"
+
+type equalOptions struct {
+	ordered bool
+}
+
+// EqualOption is the function pointer used to pass options to Equal
+type EqualOption func(*equalOptions)
+
+// OpOrderedKeys makes Equal to require the same order of object keys
+func OpOrderedKeys(o *equalOptions) { o.ordered = true }
+
+// Equal reports whether a and b are deeply equal. Numbers are compared by value regardless of their textual
+// representation, NaN is not equal to anything. Raw nodes are parsed before comparison. By default object keys are
+// compared regardless of their order
+func Equal(a, b Node, op ...EqualOption) bool {
+	var o equalOptions
+	for _, fn := range op {
+		fn(&o)
+	}
+	return o.equal(a, b)
+}
+
+func equal(a, b Node) bool {
+	var o equalOptions
+	return o.equal(a, b)
+}
+
+func (o *equalOptions) equal(a, b Node) bool {
+	if r, ok := a.(Raw); ok {
+		n, err := r.Parse()
+		if err != nil {
+			return false
+		}
+		a = n
+	}
+	if r, ok := b.(Raw); ok {
+		n, err := r.Parse()
+		if err != nil {
+			return false
+		}
+		b = n
+	}
+	switch x := a.(type) {
+	case Object:
+		y, ok := b.(Object)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		if o.ordered {
+			for i, f := range x {
+				if f.Key != y[i].Key || !o.equal(f.Value, y[i].Value) {
+					return false
+				}
+			}
+			return true
+		}
+		// members with duplicate keys are matched one to one
+		pos := make(map[string][]int, len(y))
+		for i, f := range y {
+			pos[f.Key] = append(pos[f.Key], i)
+		}
+		used := make([]bool, len(y))
+	Members:
+		for _, f := range x {
+			for _, i := range pos[f.Key] {
+				if !used[i] && o.equal(f.Value, y[i].Value) {
+					used[i] = true
+					continue Members
+				}
+			}
+			return false
+		}
+		return true
+	case Array:
+		y, ok := b.(Array)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !o.equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return scalarEqual(a, b)
+	}
+}
+
+func scalarEqual(a, b Node) bool {
+	switch x := a.(type) {
+	case String:
+		y, ok := b.(String)
+		return ok && x == y
+	case *Num:
+		y, ok := b.(*Num)
+		return ok && (*big.Float)(x).Cmp((*big.Float)(y)) == 0
+	case Bool:
+		y, ok := b.(Bool)
+		return ok && x == y
+	case Null:
+		_, ok := b.(Null)
+		return ok
+	default:
+		return false
+	}
+}
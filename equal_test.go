@@ -0,0 +1,37 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqual(t *testing.T) {
+	tst := []struct {
+		a, b    string
+		equal   bool
+		ordered bool
+	}{
+		{a: `1`, b: `1.0`, equal: true, ordered: true},
+		{a: `1e2`, b: `100`, equal: true, ordered: true},
+		{a: `"1"`, b: `1`},
+		{a: `{"a": 1, "b": [true, null]}`, b: `{"b": [true, null], "a": 1}`, equal: true},
+		{a: `{"a": 1, "b": 2}`, b: `{"a": 1, "b": 2}`, equal: true, ordered: true},
+		{a: `{"a": 1}`, b: `{"a": 1, "b": 2}`},
+		{a: `{"a": 1, "a": 1}`, b: `{"a": 1, "b": 2}`},
+		{a: `{"a": 1, "b": 2}`, b: `{"a": 1, "a": 1}`},
+		{a: `{"a": 1, "a": 2}`, b: `{"a": 2, "a": 1}`, equal: true},
+		{a: `{"a": 1, "a": 2}`, b: `{"a": 1, "a": 2}`, equal: true, ordered: true},
+		{a: `[1, 2]`, b: `[2, 1]`},
+		{a: `null`, b: `false`},
+	}
+	for _, tt := range tst {
+		a, b := mustParse(t, tt.a), mustParse(t, tt.b)
+		assert.Equal(t, tt.equal, jtree.Equal(a, b), tt.a+" "+tt.b)
+		assert.Equal(t, tt.ordered, jtree.Equal(a, b, jtree.OpOrderedKeys), tt.a+" "+tt.b)
+	}
+
+	assert.True(t, jtree.Equal(jtree.Raw(`{"a": [1]}`), mustParse(t, `{"a": [1.0]}`)))
+	assert.False(t, jtree.Equal(jtree.NaN{}, jtree.NaN{}))
+}
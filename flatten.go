@@ -0,0 +1,67 @@
+package jtree
+
+import (
+	"fmt"
+	"strings"
+)
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`)
+
+// Flatten returns the object with nested objects replaced by their members with dot separated keys like "a.b.c".
+// Dots and backslashes inside keys are escaped with backslash, see Get. Arrays and empty objects are kept as is
+func Flatten(o Object) Object {
+	out := make(Object, 0, len(o))
+	return flatten(out, o, "")
+}
+
+func flatten(out Object, o Object, prefix string) Object {
+	for _, f := range o {
+		key := prefix + dotEscaper.Replace(f.Key)
+		if obj, ok := f.Value.(Object); ok && len(obj) != 0 {
+			out = flatten(out, obj, key+".")
+		} else {
+			out = append(out, &Field{Key: key, Value: f.Value})
+		}
+	}
+	return out
+}
+
+// Unflatten reverses Flatten. It returns an error if a key denotes both a value and a nested object
+func Unflatten(o Object) (Object, error) {
+	out := make(Object, 0)
+	for _, f := range o {
+		var err error
+		if out, err = unflatten(out, splitDotted(f.Key), f); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func unflatten(o Object, path []pathElem, f *Field) (Object, error) {
+	key := path[0].key
+	i := o.index(key)
+	if len(path) == 1 {
+		if i >= 0 {
+			return nil, fmt.Errorf("jtree: conflicting key: %s", f.Key)
+		}
+		return append(o, &Field{Key: key, Value: f.Value}), nil
+	}
+	child := make(Object, 0)
+	if i >= 0 {
+		obj, ok := o[i].Value.(Object)
+		if !ok {
+			return nil, fmt.Errorf("jtree: conflicting key: %s", f.Key)
+		}
+		child = obj
+	}
+	child, err := unflatten(child, path[1:], f)
+	if err != nil {
+		return nil, err
+	}
+	if i >= 0 {
+		o[i] = &Field{Key: key, Value: child}
+		return o, nil
+	}
+	return append(o, &Field{Key: key, Value: child}), nil
+}
@@ -0,0 +1,24 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatten(t *testing.T) {
+	src := mustParse(t, `{"a": {"b": {"c": 1}, "d": [{"e": 2}]}, "x.y": {"z\\": true}, "empty": {}, "n": null}`).(jtree.Object)
+	flat := jtree.Flatten(src)
+	assert.Equal(t, mustParse(t, `{"a.b.c": 1, "a.d": [{"e": 2}], "x\\.y.z\\\\": true, "empty": {}, "n": null}`), flat)
+
+	out, err := jtree.Unflatten(flat)
+	if assert.NoError(t, err) {
+		assert.Equal(t, src, out)
+	}
+
+	_, err = jtree.Unflatten(mustParse(t, `{"a.b": 1, "a": 2}`).(jtree.Object))
+	assert.EqualError(t, err, "jtree: conflicting key: a")
+	_, err = jtree.Unflatten(mustParse(t, `{"a": 1, "a.b": 2}`).(jtree.Object))
+	assert.EqualError(t, err, "jtree: conflicting key: a.b")
+}
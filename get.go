@@ -0,0 +1,178 @@
+package jtree
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Result is the value returned by Get. The zero Result represents a missing value
+type Result struct {
+	Node Node
+}
+
+// Get returns the value located by dot separated path like "data.items.3.id". The "*" path element matches any key
+// or index, in that case the matching values are collected into an array. Backslash escapes the following character
+// inside keys i.e. "\." and "\*" stand for the literal dot and star. Raw nodes are parsed on the fly. Missing values
+// and parse errors result in the zero Result
+func Get(n Node, path string) Result {
+	return Result{Node: get(n, splitDotted(path))}
+}
+
+type pathElem struct {
+	key  string
+	wild bool
+}
+
+func splitDotted(path string) []pathElem {
+	if path == "" {
+		return []pathElem{}
+	}
+	var (
+		out     []pathElem
+		s       strings.Builder
+		escaped bool
+	)
+	flush := func() {
+		out = append(out, pathElem{key: s.String(), wild: !escaped && s.String() == "*"})
+		s.Reset()
+		escaped = false
+	}
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '\\' && i+1 < len(path):
+			i++
+			escaped = true
+			s.WriteByte(path[i])
+		case c == '.':
+			flush()
+		default:
+			s.WriteByte(c)
+		}
+	}
+	flush()
+	return out
+}
+
+func get(n Node, path []pathElem) Node {
+	for i, elem := range path {
+		if r, ok := n.(Raw); ok {
+			var err error
+			if n, err = r.Parse(); err != nil {
+				return nil
+			}
+		}
+		if elem.wild {
+			out := make(Array, 0)
+			var children []Node
+			switch node := n.(type) {
+			case Object:
+				for _, f := range node {
+					children = append(children, f.Value)
+				}
+			case Array:
+				children = node
+			default:
+				return nil
+			}
+			for _, c := range children {
+				if v := get(c, path[i+1:]); v != nil {
+					out = append(out, v)
+				}
+			}
+			return out
+		}
+		switch node := n.(type) {
+		case Object:
+			if n = node.FieldByName(elem.key); n == nil {
+				return nil
+			}
+		case Array:
+			idx, err := strconv.ParseUint(elem.key, 10, 64)
+			if err != nil || idx >= uint64(len(node)) {
+				return nil
+			}
+			n = node[idx]
+		default:
+			return nil
+		}
+	}
+	return n
+}
+
+// Get returns the value located by the path relative to r
+func (r Result) Get(path string) Result {
+	if r.Node == nil {
+		return r
+	}
+	return Get(r.Node, path)
+}
+
+// Exists returns true if the value is present
+func (r Result) Exists() bool { return r.Node != nil }
+
+// String returns the value of the string, the decimal text of the number, "true" or "false" for booleans
+// and empty string otherwise
+func (r Result) String() string {
+	switch node := r.Node.(type) {
+	case String:
+		return string(node)
+	case *Num:
+		return node.text()
+	case Bool:
+		return strconv.FormatBool(bool(node))
+	default:
+		return ""
+	}
+}
+
+// Int returns the integer value of the number truncated towards zero, or the parsed value of the numeric string.
+// Zero is returned otherwise
+func (r Result) Int() int64 {
+	switch node := r.Node.(type) {
+	case *Num:
+		v, _ := (*big.Float)(node).Int64()
+		return v
+	case String:
+		v, _ := strconv.ParseInt(string(node), 10, 64)
+		return v
+	default:
+		return 0
+	}
+}
+
+// Float returns the value of the number or the parsed value of the numeric string. Zero is returned otherwise
+func (r Result) Float() float64 {
+	switch node := r.Node.(type) {
+	case *Num:
+		v, _ := (*big.Float)(node).Float64()
+		return v
+	case String:
+		v, _ := strconv.ParseFloat(string(node), 64)
+		return v
+	default:
+		return 0
+	}
+}
+
+// Bool returns the value of the boolean. False is returned otherwise
+func (r Result) Bool() bool {
+	b, _ := r.Node.(Bool)
+	return bool(b)
+}
+
+// Array returns elements of the array, the single element slice for other existing values and nil otherwise
+func (r Result) Array() []Result {
+	switch node := r.Node.(type) {
+	case nil:
+		return nil
+	case Array:
+		out := make([]Result, len(node))
+		for i, elem := range node {
+			out[i] = Result{Node: elem}
+		}
+		return out
+	default:
+		return []Result{r}
+	}
+}
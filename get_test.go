@@ -0,0 +1,43 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	src := mustParse(t, `{
+		"data": {"items": [{"id": 1}, {"id": "2"}, {"name": "x"}, {"id": 4.5}]},
+		"a.b": {"*": true},
+		"raw": null
+	}`)
+	src.(jtree.Object)[2].Value = jtree.Raw(`{"x": [10, 20]}`)
+
+	assert.Equal(t, int64(1), jtree.Get(src, "data.items.0.id").Int())
+	assert.Equal(t, int64(2), jtree.Get(src, "data.items.1.id").Int())
+	assert.Equal(t, "4.5", jtree.Get(src, "data.items.3.id").String())
+	assert.Equal(t, 4.5, jtree.Get(src, "data").Get("items.3.id").Float())
+	assert.True(t, jtree.Get(src, `a\.b.\*`).Bool())
+	assert.Equal(t, int64(20), jtree.Get(src, "raw.x.1").Int())
+
+	for _, p := range []string{"data.items.2.id", "data.items.4", "data.items.x", "data.missing.0", "data.items.0.id.x"} {
+		r := jtree.Get(src, p)
+		assert.False(t, r.Exists(), p)
+		assert.Equal(t, "", r.String())
+		assert.Equal(t, int64(0), r.Int())
+		assert.Nil(t, r.Array())
+		assert.False(t, r.Get("x").Exists())
+	}
+
+	ids := jtree.Get(src, "data.items.*.id")
+	assert.Equal(t, mustParse(t, `[1, "2", 4.5]`), ids.Node)
+	var s []string
+	for _, r := range ids.Array() {
+		s = append(s, r.String())
+	}
+	assert.Equal(t, []string{"1", "2", "4.5"}, s)
+	assert.Equal(t, mustParse(t, `[[10, 20]]`), jtree.Get(src, "*.x").Node)
+	assert.Equal(t, src, jtree.Get(src, "").Node)
+}
@@ -0,0 +1,66 @@
+package jtree
+
+// ObjectIndex is an order-preserving copy of an object with constant time key lookups. Object itself is a plain
+// slice of fields and its lookups are linear, the index is worth building for repeated lookups in large objects.
+// With duplicate keys the first occurrence is used
+type ObjectIndex struct {
+	obj Object
+	idx map[string]int
+}
+
+// NewObjectIndex returns an index over the copy of the object fields
+func NewObjectIndex(o Object) *ObjectIndex {
+	x := ObjectIndex{obj: append(make(Object, 0, len(o)), o...), idx: make(map[string]int, len(o))}
+	for i, f := range x.obj {
+		if _, ok := x.idx[f.Key]; !ok {
+			x.idx[f.Key] = i
+		}
+	}
+	return &x
+}
+
+// Index is a shortcut for NewObjectIndex(o)
+func (o Object) Index() *ObjectIndex { return NewObjectIndex(o) }
+
+// Object returns the indexed object. Appending to the result doesn't affect the index
+func (x *ObjectIndex) Object() Object { return x.obj[:len(x.obj):len(x.obj)] }
+
+// Len returns the number of fields
+func (x *ObjectIndex) Len() int { return len(x.obj) }
+
+// Get returns the value of the field with specific name
+func (x *ObjectIndex) Get(key string) (Node, bool) {
+	if i, ok := x.idx[key]; ok {
+		return x.obj[i].Value, true
+	}
+	return nil, false
+}
+
+// Set replaces the value of the field with specific name or appends a new field
+func (x *ObjectIndex) Set(key string, value Node) {
+	if i, ok := x.idx[key]; ok {
+		x.obj[i] = &Field{Key: key, Value: value}
+		return
+	}
+	x.idx[key] = len(x.obj)
+	x.obj = append(x.obj, &Field{Key: key, Value: value})
+}
+
+// Delete removes the field with specific name. It returns false if the field doesn't exist.
+// Positions of the following fields are updated so the cost is proportional to their number
+func (x *ObjectIndex) Delete(key string) bool {
+	i, ok := x.idx[key]
+	if !ok {
+		return false
+	}
+	delete(x.idx, key)
+	x.obj = append(x.obj[:i], x.obj[i+1:]...)
+	for j := i; j < len(x.obj); j++ {
+		// fields after i moved one position back
+		k := x.obj[j].Key
+		if p, ok := x.idx[k]; !ok || p == j+1 {
+			x.idx[k] = j
+		}
+	}
+	return true
+}
@@ -3,7 +3,10 @@ package jtree
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"io"
+	"sync"
 )
 
 // minimal encoding/json compatibility layer
@@ -14,20 +17,114 @@ func Unmarshal(data []byte, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	if err := p.ExpectEOF(); err != nil {
+		return err
+	}
 	return n.Decode(v)
 }
 
 type Decoder struct {
-	p   *Parser
-	opt []Option
+	r          io.Reader
+	br         *bufio.Reader
+	p          *Parser
+	opt        []Option
+	popt       []ParserOption
+	decompress bool
+	direct     bool
 }
 
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{p: NewParser(bufio.NewReader(r))}
+	return &Decoder{r: r}
+}
+
+func (dec *Decoder) parser() (*Parser, error) {
+	if dec.p != nil {
+		return dec.p, nil
+	}
+	br := bufReaders.Get().(*bufio.Reader)
+	br.Reset(dec.r)
+	dec.br = br
+	if dec.decompress {
+		r, err := decompressReader(br)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			br = bufio.NewReader(r)
+		}
+	}
+	rd := lexers.Get().(*reader)
+	rd.reset(br)
+	dec.p = newParser(rd, dec.popt)
+	return dec.p, nil
+}
+
+var (
+	bufReaders = sync.Pool{New: func() interface{} { return bufio.NewReader(nil) }}
+	lexers     = sync.Pool{New: func() interface{} { return newReader(nil) }}
+)
+
+// Reset discards the buffered data and the parser state and makes the decoder to read from r. Options and internal
+// buffers are retained so the decoder can be reused across requests
+func (dec *Decoder) Reset(r io.Reader) {
+	if dec.p != nil && !dec.decompress {
+		dec.br.Reset(r)
+		dec.p.reset(dec.br)
+		dec.r = r
+		return
+	}
+	dec.Release()
+	dec.r = r
+}
+
+// Release returns internal buffers to the shared pool. The decoder must not be used after Release unless Reset is called
+func (dec *Decoder) Release() {
+	if dec.p != nil {
+		dec.p.r.reset(nil)
+		dec.p.r.opt = &parserOptions{}
+		dec.p.r.trace = nil
+		lexers.Put(dec.p.r)
+		dec.p = nil
+	}
+	if dec.br != nil {
+		dec.br.Reset(nil)
+		bufReaders.Put(dec.br)
+		dec.br = nil
+	}
+	dec.r = nil
+}
+
+// decompressReader sniffs gzip and zlib magic bytes and returns the decompressing reader or nil if the stream
+// looks uncompressed
+func decompressReader(br *bufio.Reader) (io.Reader, error) {
+	magic, err := br.Peek(2)
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	switch {
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		return gzip.NewReader(br)
+	case magic[0] == 0x78 && magic[1]&0x20 == 0 && (uint(magic[0])<<8|uint(magic[1]))%31 == 0:
+		// zlib header: deflate with 32K window, no preset dictionary and valid check bits.
+		// 'x' can't start a JSON value while other method bytes collide with digits
+		return zlib.NewReader(br)
+	default:
+		return nil, nil
+	}
 }
 
 func (dec *Decoder) Decode(v interface{}) error {
-	n, err := dec.p.Parse()
+	p, err := dec.parser()
+	if err != nil {
+		return err
+	}
+	if dec.direct && p.canDecodeDirect() {
+		return p.decodeDirect(v, dec.opt)
+	}
+	n, err := p.Parse()
 	if err != nil {
 		return err
 	}
@@ -37,3 +134,18 @@ func (dec *Decoder) Decode(v interface{}) error {
 func (dec *Decoder) DisallowUnknownFields() {
 	dec.opt = append(dec.opt, OpDisallowUnknownFields)
 }
+
+func (dec *Decoder) UseNumber() {
+	dec.opt = append(dec.opt, OpInterfaceNumbers(NumberJSON))
+}
+
+// DetectCompression makes the decoder to detect gzip or zlib (deflate) compressed input by its magic bytes and
+// decompress it transparently. Must be called before the first Decode call
+func (dec *Decoder) DetectCompression() {
+	dec.decompress = true
+}
+
+// Tee sets the function receiving the exact source text of every decoded value. Must be called before the first Decode call
+func (dec *Decoder) Tee(fn func(raw []byte)) {
+	dec.popt = append(dec.popt, OpTee(fn))
+}
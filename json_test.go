@@ -0,0 +1,203 @@
+package jtree_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderDetectCompression(t *testing.T) {
+	src := `{"a": 1} {"a": 2}`
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(src))
+	w.Close()
+
+	var zl bytes.Buffer
+	z := zlib.NewWriter(&zl)
+	z.Write([]byte(src))
+	z.Close()
+
+	for _, in := range []io.Reader{&gz, &zl, strings.NewReader(src)} {
+		dec := jtree.NewDecoder(in)
+		dec.DetectCompression()
+		for _, expect := range []int{1, 2} {
+			var dest struct {
+				A int `json:"a"`
+			}
+			if assert.NoError(t, dec.Decode(&dest)) {
+				assert.Equal(t, expect, dest.A)
+			}
+		}
+	}
+
+	// "80" looks like a zlib header with a small window
+	dec := jtree.NewDecoder(strings.NewReader("80"))
+	dec.DetectCompression()
+	var v int
+	if assert.NoError(t, dec.Decode(&v)) {
+		assert.Equal(t, 80, v)
+	}
+}
+
+func TestUnmarshalTrailingData(t *testing.T) {
+	var v interface{}
+	assert.NoError(t, jtree.Unmarshal([]byte("{} \n\t"), &v))
+	assert.EqualError(t, jtree.Unmarshal([]byte("{} trailing"), &v), "jtree: unexpected data after top level value at position 3: 'trailing'")
+	assert.EqualError(t, jtree.Unmarshal([]byte("[1] 2"), &v), "jtree: unexpected data after top level value at position 4: '2'")
+}
+
+func TestDecoderTee(t *testing.T) {
+	src := ` {"a": [1, 2]}
+	"str\n" 123
+[true]`
+	var raw []string
+	dec := jtree.NewDecoder(strings.NewReader(src))
+	dec.Tee(func(b []byte) { raw = append(raw, string(b)) })
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if !assert.NoError(t, err) {
+			return
+		}
+	}
+	assert.Equal(t, []string{`{"a": [1, 2]}`, `"str\n"`, `123`, `[true]`}, raw)
+}
+
+func TestDecoderDirect(t *testing.T) {
+	type item struct {
+		ID    int               `json:"id"`
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs"`
+		Extra jtree.Node        `json:"extra"`
+		Any   interface{}       `json:"any"`
+		Next  *item             `json:"next"`
+		Blob  []byte            `json:"blob,hex"`
+	}
+	src := `{"id": 1, "tags": ["a", "b"], "attrs": {"k": "v"}, "extra": [1], "any": {"x": true},
+		"next": {"id": 2, "unknown": [{}, []]}, "blob": "0102"} [{"id": 3}, null]`
+
+	dec := jtree.NewDecoder(strings.NewReader(src))
+	dec.Direct()
+	var got item
+	if assert.NoError(t, dec.Decode(&got)) {
+		var expect item
+		assert.NoError(t, jtree.NewDecoder(strings.NewReader(src)).Decode(&expect))
+		assert.Equal(t, expect, got)
+		assert.Equal(t, 2, got.Next.ID)
+		assert.Equal(t, []byte{1, 2}, got.Blob)
+	}
+	var list []*item
+	if assert.NoError(t, dec.Decode(&list)) {
+		assert.Equal(t, []*item{{ID: 3}, nil}, list)
+	}
+
+	dec = jtree.NewDecoder(strings.NewReader(`{"id": 1, "bogus": 2}`))
+	dec.Direct()
+	dec.DisallowUnknownFields()
+	assert.EqualError(t, dec.Decode(&got), "jtree: undefined field 'bogus': jtree_test.item")
+
+	dec = jtree.NewDecoder(strings.NewReader(`{"id": 1 "tags": []}`))
+	dec.Direct()
+	assert.EqualError(t, dec.Decode(&got), "jtree: unexpected token at position 9: 'tags'")
+
+	// skipped members are validated the same way Parse does
+	for src, expect := range map[string]string{
+		`{"id":1,"bogus":[1 2]}`:       "jtree: unexpected token at position 19: '2'",
+		`{"id":1,"bogus":{"a" 1 "b"}}`: "jtree: colon expected at position 21: '1'",
+	} {
+		_, err := jtree.NewParser(strings.NewReader(src)).Parse()
+		assert.EqualError(t, err, expect)
+		dec = jtree.NewDecoder(strings.NewReader(src))
+		dec.Direct()
+		assert.EqualError(t, dec.Decode(&got), expect)
+	}
+}
+
+func TestDecoderDirectNumbers(t *testing.T) {
+	type nums struct {
+		I   int     `json:"i"`
+		I8  int8    `json:"i8"`
+		U   uint    `json:"u"`
+		U16 uint16  `json:"u16"`
+		F   float64 `json:"f"`
+		F32 float32 `json:"f32"`
+	}
+	decode := func(src string, direct bool) (nums, error) {
+		dec := jtree.NewDecoder(strings.NewReader(src))
+		if direct {
+			dec.Direct()
+		}
+		var v nums
+		err := dec.Decode(&v)
+		return v, err
+	}
+	// numbers stored without nodes convert exactly like the nodes do
+	for _, src := range []string{
+		`{"i": 42, "i8": -128, "u": 7, "u16": 65535, "f": 0.1, "f32": 3.4e38}`,
+		`{"i": -0, "u": -0, "f": -0, "f32": 1e-46}`,
+		`{"i": 1e3, "i8": 1.5, "u": 123456789012345678901, "f": 9007199254740993, "f32": 16777217}`,
+		`{"i": 9223372036854775807, "f": 1.7976931348623157e308, "f32": -1.401298464324817e-45}`,
+		`{"f": 2.2250738585072011e-308, "f32": 0.30000001192092896}`,
+		`{"i8": 128}`,
+		`{"u": -1}`,
+		`{"i": 9223372036854775808}`,
+		`{"f": 1e309}`,
+		`{"f32": 3.5e38}`,
+		`{"f": 1.2.3}`,
+	} {
+		expect, expectErr := decode(src, false)
+		got, err := decode(src, true)
+		if expectErr != nil {
+			assert.EqualError(t, err, expectErr.Error(), src)
+		} else if assert.NoError(t, err, src) {
+			assert.Equal(t, expect, got, src)
+		}
+	}
+
+	var f float64
+	dec := jtree.NewDecoder(strings.NewReader(`-0 0.1`))
+	dec.Direct()
+	if assert.NoError(t, dec.Decode(&f)) {
+		assert.True(t, math.Signbit(f))
+	}
+	if assert.NoError(t, dec.Decode(&f)) {
+		assert.Equal(t, 0.1, f)
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	type msg struct {
+		A int `json:"a"`
+	}
+	dec := jtree.NewDecoder(strings.NewReader(`{"a": 1} {"a": `))
+	var m msg
+	if assert.NoError(t, dec.Decode(&m)) {
+		assert.Equal(t, 1, m.A)
+	}
+	assert.Error(t, dec.Decode(&m))
+
+	// the unread data and the error state are discarded
+	for _, src := range []string{`{"a": 2} trailing`, `{"a": 3}`} {
+		dec.Reset(strings.NewReader(src))
+		if assert.NoError(t, dec.Decode(&m)) {
+			assert.Equal(t, src[6]-'0', byte(m.A))
+		}
+	}
+
+	dec.Release()
+	dec.Reset(strings.NewReader(`{"a": 4}`))
+	if assert.NoError(t, dec.Decode(&m)) {
+		assert.Equal(t, 4, m.A)
+	}
+	assert.Equal(t, io.EOF, dec.Decode(&m))
+}
@@ -0,0 +1,45 @@
+package jtree
+
+// MergeStrategy defines how Merge combines values
+type MergeStrategy int
+
+const (
+	// MergeReplace replaces top level object members of dst with ones of src
+	MergeReplace MergeStrategy = iota
+	// MergeRecursive merges nested objects recursively, other values including arrays are replaced
+	MergeRecursive
+	// MergeAppendArrays merges nested objects recursively and concatenates arrays
+	MergeAppendArrays
+)
+
+// Merge returns the result of layering src over dst. Values which can't be combined according to the strategy
+// are replaced by src.
+// Unlike MergePatch null values are copied as is. Arguments are not modified but the result may share subtrees with them
+func Merge(dst, src Node, strategy MergeStrategy) Node {
+	return merge(dst, src, strategy, true)
+}
+
+func merge(dst, src Node, strategy MergeStrategy, top bool) Node {
+	switch s := src.(type) {
+	case Object:
+		d, ok := dst.(Object)
+		if !ok || !top && strategy == MergeReplace {
+			return src
+		}
+		out := NewObjectIndex(d)
+		for _, f := range s {
+			if v, ok := out.Get(f.Key); ok {
+				out.Set(f.Key, merge(v, f.Value, strategy, false))
+			} else {
+				out.Set(f.Key, f.Value)
+			}
+		}
+		return out.Object()
+	case Array:
+		if d, ok := dst.(Array); ok && strategy == MergeAppendArrays {
+			out := make(Array, 0, len(d)+len(s))
+			return append(append(out, d...), s...)
+		}
+	}
+	return src
+}
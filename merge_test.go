@@ -0,0 +1,29 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	dst := mustParse(t, `{"a": {"x": 1, "y": [1]}, "b": [1, 2], "c": 1}`)
+	src := mustParse(t, `{"a": {"y": [2], "z": null}, "b": [3], "d": "new"}`)
+	orig := dst.Clone()
+
+	tests := []struct {
+		strategy jtree.MergeStrategy
+		expect   string
+	}{
+		{jtree.MergeReplace, `{"a": {"y": [2], "z": null}, "b": [3], "c": 1, "d": "new"}`},
+		{jtree.MergeRecursive, `{"a": {"x": 1, "y": [2], "z": null}, "b": [3], "c": 1, "d": "new"}`},
+		{jtree.MergeAppendArrays, `{"a": {"x": 1, "y": [1, 2], "z": null}, "b": [1, 2, 3], "c": 1, "d": "new"}`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, mustParse(t, tt.expect), jtree.Merge(dst, src, tt.strategy))
+	}
+	assert.Equal(t, orig, dst)
+	assert.Equal(t, jtree.String("x"), jtree.Merge(dst, jtree.String("x"), jtree.MergeRecursive))
+	assert.Equal(t, src, jtree.Merge(jtree.Null{}, src, jtree.MergeRecursive))
+}
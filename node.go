@@ -1,13 +1,21 @@
 // Package jtree is the AST centered JSON parser
+//
+// The package requires Go 1.20 or later. It uses errors.Join for OpCollectErrors, sync/atomic types for the default
+// registries and the encoding cache, reflect.Value.Grow and SetZero for direct decoding, and unsafe.String for the
+// jtree_unsafe build tag
 package jtree
 
 import (
+	"bytes"
 	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,27 +24,87 @@ type Context struct {
 	noUnknown bool
 	typeReg   *TypeRegistry
 	encReg    *EncodingRegistry
+	numbers   NumberMode
+	proto     bool
+
+	requireFields bool
+	hooks         []DecodeHook
+	strictTypes   bool
+	noNull        bool
+	merge         bool
+	cancel        *canceler
+	maxDepth      int
+	collect       bool
+	values        *contextValue
+	statsFn       func(DecodeStats)
+	stats         *decodeStats // counters of the running top level call
+}
+
+// NumberMode specifies the type used for numbers decoded into empty interface values
+type NumberMode int
+
+const (
+	// NumberFloat64 makes numbers to be decoded as float64 (default)
+	NumberFloat64 NumberMode = iota
+	// NumberJSON makes numbers to be decoded as json.Number preserving integers of any size
+	NumberJSON
+	// NumberNode makes numbers to be stored as *Num nodes
+	NumberNode
+	// NumberText makes numbers to be decoded as Number preserving integers of any size
+	NumberText
+)
+
+// DefaultMaxDecodeDepth is the nesting limit of destination values used unless OpMaxDecodeDepth is specified
+const DefaultMaxDecodeDepth = 10000
+
+func (c *Context) maxDecodeDepth() int {
+	if c.maxDepth != 0 {
+		return c.maxDepth
+	}
+	return DefaultMaxDecodeDepth
 }
 
 func (c *Context) types() *TypeRegistry {
 	if c.typeReg != nil {
 		return c.typeReg
 	}
-	return defaultTypeRegistry
+	return DefaultTypeRegistry()
 }
 
 func (c *Context) encodings() *EncodingRegistry {
 	if c.encReg != nil {
 		return c.encReg
 	}
-	return defaultEncodingRegistry
+	return DefaultEncodingRegistry()
 }
 
 type options struct {
-	context *Context
-	str     bool
-	enc     Encoding
-	elem    *options
+	context  *Context
+	ownCtx   bool // the context was created for these options and can be modified in place
+	str      bool
+	enc      Encoding
+	elem     *options
+	key      *options
+	computed []computedField
+	unit     time.Duration
+	notNull  bool
+	depth    int
+}
+
+func (o *options) durationUnit() time.Duration {
+	if o.unit != 0 {
+		return o.unit
+	}
+	return time.Nanosecond
+}
+
+// durationOf returns the number of units as a duration truncated towards zero. It returns false on overflow
+func durationOf(f *big.Float, opt *options) (time.Duration, bool) {
+	d, acc := new(big.Float).Mul(f, new(big.Float).SetInt64(int64(opt.durationUnit()))).Int64()
+	if d == math.MaxInt64 && acc == big.Below || d == math.MinInt64 && acc == big.Above {
+		return 0, false
+	}
+	return time.Duration(d), true
 }
 
 func (o *options) apply(opts []Option) *options {
@@ -50,7 +118,20 @@ func (o *options) ctx() *Context {
 	if o.context != nil {
 		return o.context
 	}
-	o.context = new(Context)
+	o.context, o.ownCtx = new(Context), true
+	return o.context
+}
+
+// mutableCtx returns the context which can be modified by global options. The context passed using OpCtx
+// is copied first so it can be shared between calls
+func (o *options) mutableCtx() *Context {
+	if !o.ownCtx {
+		var c Context
+		if o.context != nil {
+			c = *o.context
+		}
+		o.context, o.ownCtx = &c, true
+	}
 	return o.context
 }
 
@@ -61,15 +142,93 @@ func OpString(o *options) { o.str = true }
 // OpEncoding specifies the binary encoding scheme used for byte slices. Without this option base64 scheme will be used
 func OpEncoding(e Encoding) Option { return func(o *options) { o.enc = e } }
 
+// OpDurationUnit sets the unit of numbers decoded into time.Duration values. Strings without a unit suffix
+// are interpreted the same way. The default unit is nanosecond. Field tag equivalent is unit=<suffix>
+// like `json:"timeout,unit=s"`
+func OpDurationUnit(unit time.Duration) Option { return func(o *options) { o.unit = unit } }
+
 // OpTypes provides custom user type registry. The option is global for all Decode calls in chain
-func OpTypes(r *TypeRegistry) Option { return func(o *options) { o.ctx().typeReg = r } }
+func OpTypes(r *TypeRegistry) Option { return func(o *options) { o.mutableCtx().typeReg = r } }
 
 // OpEncodings provides custom user encodings registry. The option is global for all Decode calls in chain
-func OpEncodings(e *EncodingRegistry) Option { return func(o *options) { o.ctx().encReg = e } }
+func OpEncodings(e *EncodingRegistry) Option { return func(o *options) { o.mutableCtx().encReg = e } }
 
 // OpDisallowUnknownFields causes the Decode method to return an error when the destination is a struct
 // and the input contains object keys which do not match any non-ignored, exported fields in the destination.
-func OpDisallowUnknownFields(o *options) { o.ctx().noUnknown = true }
+func OpDisallowUnknownFields(o *options) { o.mutableCtx().noUnknown = true }
+
+// OpRequireFields makes all struct fields required except ones tagged with omitempty and Optional fields.
+// The option is global for all Decode calls in chain
+func OpRequireFields(o *options) { o.mutableCtx().requireFields = true }
+
+// OpMergeExisting makes Decode to add object members to existing non nil maps instead of allocating new ones,
+// keeping entries missing in the input, and to reuse the capacity of existing slices.
+// The option is global for all Decode calls in chain
+func OpMergeExisting(o *options) { o.mutableCtx().merge = true }
+
+// OpDisallowNull causes the Decode method to return an error when null is decoded into a destination other than
+// a pointer, an interface, a map, a slice, a channel or a function. Field tag notnull disallows null values
+// for the specific field regardless of its type. The option is global for all Decode calls in chain
+func OpDisallowNull(o *options) { o.mutableCtx().noNull = true }
+
+func opNotNull(o *options) { o.notNull = true }
+
+// OpMaxDecodeDepth limits the nesting of decoded values independently of the parser. Negative value disables the check.
+// The option is global for all Decode calls in chain
+func OpMaxDecodeDepth(depth int) Option { return func(o *options) { o.mutableCtx().maxDepth = depth } }
+
+// OpStrictTypes disables implicit cross-kind conversions like number to bool or string and boolean to number
+// or string. Conversions explicitly requested by OpString are still performed.
+// The option is global for all Decode calls in chain
+func OpStrictTypes(o *options) { o.mutableCtx().strictTypes = true }
+
+// DecodeHook is consulted before the built-in conversion logic. If it returns true then the returned value
+// is assigned or converted to the target type and stored. nil value stores the zero value
+type DecodeHook func(node Node, target reflect.Type) (value interface{}, ok bool, err error)
+
+// OpDecodeHook adds the decode hook. Hooks are consulted in the order of addition, the first one returning true wins.
+// Pointer targets are offered to hooks both as is and dereferenced. The option is global for all Decode calls in chain
+func OpDecodeHook(h DecodeHook) Option {
+	return func(o *options) {
+		ctx := o.mutableCtx()
+		ctx.hooks = append(ctx.hooks[:len(ctx.hooks):len(ctx.hooks)], h)
+	}
+}
+
+func applyHooks(node Node, out reflect.Value, opt *options) (bool, error) {
+	if opt.context == nil {
+		return false, nil
+	}
+	t := out.Type()
+	for _, h := range opt.context.hooks {
+		v, ok, err := h(node, t)
+		if err != nil {
+			return true, err
+		}
+		if !ok {
+			continue
+		}
+		if v == nil {
+			out.Set(reflect.Zero(t))
+			return true, nil
+		}
+		val := reflect.ValueOf(v)
+		switch {
+		case val.Type().AssignableTo(t):
+			out.Set(val)
+		case val.CanConvert(t):
+			out.Set(val.Convert(t))
+		default:
+			return true, fmt.Errorf("jtree: hook returned %v for %v", val.Type(), t)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// OpInterfaceNumbers specifies the type used for numbers decoded into empty interface values including
+// map[string]interface{} and []interface{} elements. The option is global for all Decode calls in chain
+func OpInterfaceNumbers(m NumberMode) Option { return func(o *options) { o.mutableCtx().numbers = m } }
 
 // OpElem passes options to container elements
 func OpElem(op ...Option) Option {
@@ -81,25 +240,38 @@ func OpElem(op ...Option) Option {
 	}
 }
 
-// OpCtx passes global options to subsequent Decode calls. Used in custom decoders
-func OpCtx(ctx *Context) Option { return func(o *options) { o.context = ctx } }
-
-func opInit(src *options) Option {
+// OpKey passes options to object keys decoded into map keys, e.g. OpKey(OpEncoding(Hex))
+func OpKey(op ...Option) Option {
 	return func(o *options) {
-		*o = *src
-		o.elem = nil
+		if o.key == nil {
+			o.key = new(options)
+		}
+		o.key.apply(op)
 	}
 }
 
+// OpCtx passes global options to subsequent Decode calls. Used in custom decoders. The context is not modified by
+// global options following OpCtx, they apply to the copy of it. Global options preceding OpCtx are overridden
+func OpCtx(ctx *Context) Option { return func(o *options) { o.context, o.ownCtx = ctx, false } }
+
+// opCopy passes all options to the Decode call of the same value. The options are copied as the source may be reused
+func opCopy(src *options) Option {
+	cp := *src
+	cp.ownCtx = false
+	return func(o *options) { *o = cp }
+}
+
 // Option is the function pointer used to pass options to Decode method
 type Option func(*options)
 
 // Node is the JSON AST node
 type Node interface {
-	// Type returns Node type name: "number", "string", "object", "array", "boolean" or "null"
+	// Type returns Node type name: "number", "string", "object", "array", "boolean", "null" or "raw"
 	Type() string
 	// Decode decodes the node into the value pointed by v
 	Decode(v interface{}, op ...Option) error
+	// Clone returns a deep copy of the node
+	Clone() Node
 	/*
 		// TODO
 		String() string
@@ -112,57 +284,149 @@ type JSONDecoder interface {
 	DecodeJSON(node Node) error
 }
 
+// JSONContextDecoder is the JSONDecoder variant receiving the decoding context. ctx must be passed to nested
+// Decode calls using OpCtx. It takes precedence over JSONDecoder
+type JSONContextDecoder interface {
+	DecodeJSONContext(node Node, ctx *Context) error
+}
+
 // Num represents numeric node
 type Num big.Float // on conversion operations the difference in performance between big.Float and big.Int is insignificant
 
 // Type returns the node type i.e. "number"
 func (*Num) Type() string { return "number" }
 
+// Clone returns a copy of the number
+func (n *Num) Clone() Node { return (*Num)(new(big.Float).Copy((*big.Float)(n))) }
+
 // Decode decodes the node into the value pointed by v
 func (n *Num) Decode(v interface{}, op ...Option) error {
-	fn := func(out reflect.Value, opt *options) error {
-		switch out.Type() {
-		case bigIntType:
-			i, _ := (*big.Float)(n).Int(nil)
-			out.Set(reflect.ValueOf(*i))
+	return decodeNode(v, n, op...)
+}
 
-		case bigFloatType:
-			out.Set(reflect.ValueOf(*(*big.Float)(n)))
+func (n *Num) decodeValue(out reflect.Value, opt *options) error {
+	switch out.Type() {
+	case bigIntType:
+		i, _ := (*big.Float)(n).Int(nil)
+		out.Set(reflect.ValueOf(*i))
 
-		case timeType:
-			u, _ := (*big.Float)(n).Int64()
-			tmp := time.Unix(u, 0).UTC()
-			out.Set(reflect.ValueOf(tmp))
+	case bigFloatType:
+		out.Set(reflect.ValueOf(*(*big.Float)(n)))
 
-		default:
-			k := out.Kind()
-			switch {
-			case k >= reflect.Int && k <= reflect.Int64:
-				i, _ := (*big.Float)(n).Int64()
-				out.SetInt(i)
+	case jsonNumberType, numberType:
+		out.SetString(n.text())
 
-			case k >= reflect.Uint && k <= reflect.Uintptr:
-				u, _ := (*big.Float)(n).Uint64()
-				out.SetUint(u)
+	case durationType:
+		d, ok := durationOf((*big.Float)(n), opt)
+		if !ok {
+			return fmt.Errorf("jtree: number %v overflows %v", (*big.Float)(n), out.Type())
+		}
+		out.SetInt(int64(d))
 
-			case k == reflect.Float32 || k == reflect.Float64:
-				f, _ := (*big.Float)(n).Float64()
-				out.SetFloat(f)
+	case timeType:
+		u, _ := (*big.Float)(n).Int64()
+		tmp := time.Unix(u, 0).UTC()
+		out.Set(reflect.ValueOf(tmp))
 
-			case k == reflect.String:
-				out.SetString((*big.Float)(n).String())
+	default:
+		k := out.Kind()
+		switch {
+		case k >= reflect.Int && k <= reflect.Int64:
+			i, ok := n.int64Value()
+			if !ok || out.OverflowInt(i) {
+				return fmt.Errorf("jtree: number %v overflows %v", (*big.Float)(n), out.Type())
+			}
+			out.SetInt(i)
 
-			case k == reflect.Bool:
-				v := (*big.Float)(n).Cmp(big.NewFloat(0)) != 0
-				out.SetBool(v)
+		case k >= reflect.Uint && k <= reflect.Uintptr:
+			i, ok := n.uint64Value()
+			if !ok || out.OverflowUint(i) {
+				return fmt.Errorf("jtree: number %v overflows %v", (*big.Float)(n), out.Type())
+			}
+			out.SetUint(i)
 
-			default:
-				return fmt.Errorf("jtree: can't convert number to %v", out.Type())
+		case k == reflect.Float32 || k == reflect.Float64:
+			f, _ := (*big.Float)(n).Float64()
+			if !(*big.Float)(n).IsInf() && (math.IsInf(f, 0) || out.OverflowFloat(f)) {
+				return fmt.Errorf("jtree: number %v overflows %v", (*big.Float)(n), out.Type())
 			}
+			out.SetFloat(f)
+
+		case k == reflect.String && !opt.ctx().strictTypes:
+			out.SetString(n.text())
+
+		case k == reflect.Bool && !opt.ctx().strictTypes:
+			v := (*big.Float)(n).Cmp(big.NewFloat(0)) != 0
+			out.SetBool(v)
+
+		default:
+			return fmt.Errorf("jtree: can't convert number to %v", out.Type())
 		}
-		return nil
 	}
-	return decodeNode(v, n, fn, op...)
+	return nil
+}
+
+// NaN represents not-a-number value accepted by the parser with OpAllowNonFinite option
+type NaN struct{}
+
+// Type returns the node type i.e. "number"
+func (NaN) Type() string { return "number" }
+
+// Clone returns the node itself
+func (n NaN) Clone() Node { return n }
+
+// Decode decodes the node into the value pointed by v. Only floating point destinations are supported
+func (n NaN) Decode(v interface{}, op ...Option) error {
+	return decodeNode(v, n, op...)
+}
+
+func (n NaN) decodeValue(out reflect.Value, opt *options) error {
+	k := out.Kind()
+	if k != reflect.Float32 && k != reflect.Float64 {
+		return fmt.Errorf("jtree: can't convert NaN to %v", out.Type())
+	}
+	out.SetFloat(math.NaN())
+	return nil
+}
+
+// IsInt returns true if the number is an integer
+func (n *Num) IsInt() bool { return (*big.Float)(n).IsInt() }
+
+// Int64 returns the value of the number if it's an integer fitting into int64
+func (n *Num) Int64() (int64, bool) {
+	if !n.IsInt() {
+		return 0, false
+	}
+	v, acc := (*big.Float)(n).Int64()
+	return v, acc == big.Exact
+}
+
+// Float64 returns the nearest float64 value of the number. It returns false if the number is out of float64 range
+// i.e. the finite number is converted to an infinity or the non-zero number is converted to zero
+func (n *Num) Float64() (float64, bool) {
+	f := (*big.Float)(n)
+	v, _ := f.Float64()
+	if math.IsInf(v, 0) && !f.IsInf() || v == 0 && f.Sign() != 0 {
+		return v, false
+	}
+	return v, true
+}
+
+// text returns the exact textual representation suitable for json.Number. Integers are formatted without exponent,
+// other values use the shortest representation distinguishing them from adjacent ones
+func (n *Num) text() string {
+	f := (*big.Float)(n)
+	if f.IsInt() {
+		if i, acc := f.Int64(); acc == big.Exact {
+			return strconv.FormatInt(i, 10)
+		}
+		i, _ := f.Int(nil)
+		return i.String()
+	}
+	if v, acc := f.Float64(); acc == big.Exact && f.Prec() <= 53 {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return f.Text('g', -1)
 }
 
 // String represents string node
@@ -171,85 +435,111 @@ type String string
 // Type returns the node i.e. "string"
 func (String) Type() string { return "string" }
 
+// Clone returns the node itself as strings are immutable
+func (s String) Clone() Node { return s }
+
 // Decode decodes the node into the value pointed by v
 func (s String) Decode(v interface{}, op ...Option) error {
-	fn := func(out reflect.Value, opt *options) error {
-		t := out.Type()
-		switch {
-		case reflect.PtrTo(t).Implements(textUnmarshalerType) && out.CanAddr():
-			unmarshaler := out.Addr().Interface().(encoding.TextUnmarshaler)
-			if err := unmarshaler.UnmarshalText([]byte(s)); err != nil {
-				return fmt.Errorf("jtree: %w", err)
-			}
+	return decodeNode(v, s, op...)
+}
 
-		case t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
-			var src reflect.Value
-			enc := opt.enc
-			if enc == nil && t.Kind() != reflect.String && !opt.str {
-				enc = Base64
+func (s String) decodeValue(out reflect.Value, opt *options) error {
+	t := out.Type()
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(string(s))
+		if err != nil {
+			// unitless number
+			f, _, ferr := new(big.Float).Parse(string(s), 10)
+			if ferr != nil {
+				return fmt.Errorf("jtree: %w", err)
 			}
-			if enc != nil {
-				buf, err := enc.Decode([]byte(s))
-				if err != nil {
-					return fmt.Errorf("jtree: %w", err)
-				}
-				src = reflect.ValueOf(buf)
-			} else {
-				src = reflect.ValueOf(string(s))
+			var ok bool
+			if d, ok = durationOf(f, opt); !ok {
+				return fmt.Errorf("jtree: number %v overflows %v", f, out.Type())
 			}
-			if !src.CanConvert(t) {
-				return fmt.Errorf("jtree: can't convert string to %v", t)
+		}
+		out.SetInt(int64(d))
+
+	case reflect.PtrTo(t).Implements(textUnmarshalerType) && out.CanAddr():
+		unmarshaler := out.Addr().Interface().(encoding.TextUnmarshaler)
+		if err := unmarshaler.UnmarshalText(stringToBytes(string(s))); err != nil {
+			return fmt.Errorf("jtree: %w", err)
+		}
+
+	case t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		var src reflect.Value
+		enc := opt.enc
+		if enc == nil && t.Kind() != reflect.String && !opt.str {
+			enc = Base64
+		}
+		if enc != nil {
+			buf, err := enc.Decode(stringToBytes(string(s)))
+			if err != nil {
+				return fmt.Errorf("jtree: %w", err)
 			}
-			out.Set(src.Convert(t))
+			src = reflect.ValueOf(buf)
+		} else {
+			src = reflect.ValueOf(string(s))
+		}
+		if !src.CanConvert(t) {
+			return fmt.Errorf("jtree: can't convert string to %v", t)
+		}
+		out.Set(src.Convert(t))
 
-		default:
-			if !opt.str {
-				return fmt.Errorf("jtree: can't convert string to %v", t)
-			}
-			k := out.Kind()
-			switch {
-			case t == bigIntType:
-				i, ok := new(big.Int).SetString(string(s), 10)
-				if !ok {
-					return fmt.Errorf("jtree: error parsing integer number: %s", s)
-				}
-				out.Set(reflect.ValueOf(*i))
+	default:
+		if !opt.str {
+			return fmt.Errorf("jtree: can't convert string to %v", t)
+		}
+		k := out.Kind()
+		switch {
+		case t == bigIntType:
+			i, ok := new(big.Int).SetString(string(s), 10)
+			if !ok {
+				return fmt.Errorf("jtree: error parsing integer number: %s", s)
+			}
+			out.Set(reflect.ValueOf(*i))
 
-			case t == bigFloatType:
-				f, _, err := new(big.Float).Parse(string(s), 10)
-				if err != nil {
-					return fmt.Errorf("jtree: %w", err)
-				}
-				out.Set(reflect.ValueOf(*f))
+		case t == bigFloatType:
+			f, _, err := new(big.Float).Parse(string(s), 10)
+			if err != nil {
+				return fmt.Errorf("jtree: %w", err)
+			}
+			out.Set(reflect.ValueOf(*f))
 
-			case k >= reflect.Int && k <= reflect.Int64:
-				i, err := strconv.ParseInt(string(s), 10, 64)
-				if err != nil {
-					return fmt.Errorf("jtree: %w", err)
-				}
-				out.SetInt(i)
+		case k >= reflect.Int && k <= reflect.Int64:
+			i, err := strconv.ParseInt(string(s), 10, t.Bits())
+			if err != nil {
+				return fmt.Errorf("jtree: %w", err)
+			}
+			out.SetInt(i)
 
-			case k >= reflect.Uint && k <= reflect.Uintptr:
-				i, err := strconv.ParseUint(string(s), 10, 64)
-				if err != nil {
-					return fmt.Errorf("jtree: %w", err)
-				}
-				out.SetUint(i)
+		case k >= reflect.Uint && k <= reflect.Uintptr:
+			i, err := strconv.ParseUint(string(s), 10, t.Bits())
+			if err != nil {
+				return fmt.Errorf("jtree: %w", err)
+			}
+			out.SetUint(i)
 
-			case k == reflect.Bool:
-				v, err := strconv.ParseBool(string(s))
-				if err != nil {
-					return fmt.Errorf("jtree: %w", err)
-				}
-				out.SetBool(v)
+		case k == reflect.Float32 || k == reflect.Float64:
+			f, err := strconv.ParseFloat(string(s), t.Bits())
+			if err != nil {
+				return fmt.Errorf("jtree: %w", err)
+			}
+			out.SetFloat(f)
 
-			default:
-				return fmt.Errorf("jtree: can't convert string to %v", t)
+		case k == reflect.Bool:
+			v, err := strconv.ParseBool(string(s))
+			if err != nil {
+				return fmt.Errorf("jtree: %w", err)
 			}
+			out.SetBool(v)
+
+		default:
+			return fmt.Errorf("jtree: can't convert string to %v", t)
 		}
-		return nil
 	}
-	return decodeNode(v, s, fn, op...)
+	return nil
 }
 
 // Object represents object node
@@ -273,13 +563,21 @@ func (o Object) Keys() []string {
 	return keys
 }
 
-// FieldByName returns the field with specific name or nil
-func (o Object) FieldByName(field string) Node {
-	for _, f := range o {
-		if f.Key == field {
-			return f.Value
+func (o Object) index(key string) int {
+	for i, f := range o {
+		if f.Key == key {
+			return i
 		}
 	}
+	return -1
+}
+
+// FieldByName returns the field with specific name or nil. The lookup is linear, use ObjectIndex for repeated
+// lookups in large objects
+func (o Object) FieldByName(field string) Node {
+	if i := o.index(field); i >= 0 {
+		return o[i].Value
+	}
 	return nil
 }
 
@@ -296,63 +594,290 @@ func (o Object) NumField() int {
 	return len(o)
 }
 
+// Set replaces the value of the field with specific name or appends a new field
+func (o *Object) Set(key string, value Node) {
+	if i := o.index(key); i >= 0 {
+		(*o)[i] = &Field{Key: key, Value: value}
+	} else {
+		*o = append(*o, &Field{Key: key, Value: value})
+	}
+}
+
+// Delete removes the field with specific name. It returns false if the field doesn't exist
+func (o *Object) Delete(key string) bool {
+	i := o.index(key)
+	if i < 0 {
+		return false
+	}
+	*o = append((*o)[:i], (*o)[i+1:]...)
+	return true
+}
+
+// InsertAt inserts a new field at i'th position. An existing field with the same name is removed first.
+// It panics if i is out of range
+func (o *Object) InsertAt(i int, key string, value Node) {
+	if i < 0 || i > len(*o) {
+		panic(fmt.Sprintf("jtree: index out of range: %d", i))
+	}
+	if j := o.index(key); j >= 0 {
+		*o = append((*o)[:j], (*o)[j+1:]...)
+		if j < i {
+			i--
+		}
+	}
+	*o = append(*o, nil)
+	copy((*o)[i+1:], (*o)[i:])
+	(*o)[i] = &Field{Key: key, Value: value}
+}
+
+// Rename renames the field keeping its position. An existing field with the new name is removed.
+// It returns false if the field doesn't exist
+func (o *Object) Rename(oldKey, newKey string) bool {
+	i := o.index(oldKey)
+	if i < 0 {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if j := o.index(newKey); j >= 0 {
+		*o = append((*o)[:j], (*o)[j+1:]...)
+		if j < i {
+			i--
+		}
+	}
+	(*o)[i] = &Field{Key: newKey, Value: (*o)[i].Value}
+	return true
+}
+
+// Clone returns a deep copy of the object
+func (o Object) Clone() Node {
+	if o == nil {
+		return o
+	}
+	out := make(Object, len(o))
+	for i, f := range o {
+		out[i] = &Field{Key: f.Key, Value: f.Value.Clone()}
+	}
+	return out
+}
+
 // Decode decodes the node into the value pointed by v
 func (o Object) Decode(v interface{}, op ...Option) error {
-	fn := func(out reflect.Value, opt *options) error {
-		t := out.Type()
-		switch t.Kind() {
-		case reflect.Struct:
-			fields := make(map[string]*StructField)
-			collectFields(t, nil, nil, fields)
-			for i := 0; i < o.NumField(); i++ {
-				key, elem := o.Field(i)
-				field, ok := fields[key]
-				if !ok {
-					if opt.ctx().noUnknown {
-						return fmt.Errorf("jtree: undefined field '%s': %v", key, out.Type())
+	return decodeNode(v, o, op...)
+}
+
+func (o Object) decodeValue(out reflect.Value, opt *options) error {
+	t := out.Type()
+	switch t.Kind() {
+	case reflect.Struct:
+		plan := planFor(t)
+		if plan.err != nil {
+			return plan.err
+		}
+		fields, inline := plan.fields, plan.inline
+		seen := make(map[string]bool, len(o))
+		errs := newErrorCollector(opt)
+		var child options // reused by members
+		for i := 0; i < o.NumField(); i++ {
+			key, elem := o.Field(i)
+			field, ok := fields[key]
+			if !ok {
+				if inline != nil {
+					if !errs.add(key, decodeInline(out, inline, key, elem, opt, &child)) {
+						return errs.err()
 					}
 					continue
 				}
-				dest := out
-				for i, fi := range field.Index {
-					dest = dest.Field(fi)
-					if i < len(field.Index)-1 && dest.Kind() == reflect.Ptr {
-						// allocate anonymous fields
-						if dest.IsNil() {
-							dest.Set(reflect.New(dest.Type().Elem()))
-						}
-						dest = dest.Elem()
-					}
-				}
-				fopt := parseFieldOptions(field.Options, opt)
-				if err := elem.Decode(dest.Addr().Interface(), mkChildOptions(opt, fopt)...); err != nil {
-					return err
+				if opt.ctx().noUnknown && !errs.add(key, fmt.Errorf("jtree: undefined field '%s': %v", key, out.Type())) {
+					return errs.err()
 				}
+				continue
 			}
-			return nil
+			seen[key] = true
+			if !errs.add(key, decodeField(out, o, field, elem, opt, &child)) {
+				return errs.err()
+			}
+		}
+		for _, c := range opt.computed {
+			field, ok := fields[c.name]
+			if !ok {
+				return fmt.Errorf("jtree: undefined computed field '%s': %v", c.name, out.Type())
+			}
+			elem, err := c.expr(o)
+			if err == nil {
+				seen[c.name] = true
+				err = decodeField(out, o, field, elem, opt, &child)
+			}
+			if !errs.add(c.name, err) {
+				return errs.err()
+			}
+		}
+		var missing []string
+		for _, f := range plan.list {
+			if !seen[f.Name] && f.required(opt.ctx().requireFields) {
+				missing = append(missing, f.Name)
+			}
+		}
+		if len(missing) != 0 {
+			errs.add("", fmt.Errorf("jtree: missing required fields %s: %v", strings.Join(missing, ", "), out.Type()))
+		}
+		return errs.err()
 
-		case reflect.Map:
-			dst := reflect.MakeMap(t)
-			for i := 0; i < o.NumField(); i++ {
-				key, elem := o.Field(i)
-				keyVal := reflect.New(t.Key())
-				if err := String(key).Decode(keyVal.Interface(), OpString); err != nil {
-					return err
-				}
-				elemVal := reflect.New(t.Elem())
-				if err := elem.Decode(elemVal.Interface(), mkChildOptions(opt, nil)...); err != nil {
-					return err
+	case reflect.Map:
+		dst := out
+		if out.IsNil() || !opt.ctx().merge {
+			dst = reflect.MakeMap(t)
+		}
+		errs := newErrorCollector(opt)
+		var child options
+		for i := 0; i < o.NumField(); i++ {
+			key, elem := o.Field(i)
+			var (
+				keyVal reflect.Value
+				err    error
+			)
+			if k := opt.key; k != nil && (k.enc != nil || k.str) {
+				keyOpt := *opt.key
+				keyOpt.elem, keyOpt.context, keyOpt.ownCtx = nil, opt.context, false
+				keyVal = reflect.New(t.Key()).Elem()
+				err = decodeInto(String(key), keyVal, &keyOpt)
+			} else {
+				keyVal, err = decodeMapKey(key, t.Key())
+			}
+			if err == nil {
+				elemVal := reflect.New(t.Elem()).Elem()
+				if err = decodeChild(elem, elemVal, opt.child(&child)); err == nil {
+					dst.SetMapIndex(keyVal, elemVal)
 				}
-				dst.SetMapIndex(keyVal.Elem(), elemVal.Elem())
 			}
-			out.Set(dst)
-			return nil
+			if !errs.add(key, err) {
+				return errs.err()
+			}
+		}
+		out.Set(dst)
+		return errs.err()
+
+	default:
+		return fmt.Errorf("jtree: struct or map expected: %v", t)
+	}
+}
 
+// decodeMapKey converts the object key to the map key of type t
+func decodeMapKey(key string, t reflect.Type) (reflect.Value, error) {
+	out := reflect.New(t).Elem()
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		if err := out.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return out, fmt.Errorf("jtree: %w", err)
+		}
+		return out, nil
+	}
+	var err error
+	k := t.Kind()
+	switch {
+	case k == reflect.String:
+		out.SetString(key)
+	case k == reflect.Interface && t.NumMethod() == 0:
+		out.Set(reflect.ValueOf(key))
+	case k >= reflect.Int && k <= reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(key, 10, t.Bits()); err == nil {
+			out.SetInt(i)
+		}
+	case k >= reflect.Uint && k <= reflect.Uintptr:
+		var u uint64
+		if u, err = strconv.ParseUint(key, 10, t.Bits()); err == nil {
+			out.SetUint(u)
+		}
+	case k == reflect.Float32 || k == reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(key, t.Bits()); err == nil {
+			out.SetFloat(f)
+		}
+	case k == reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(key); err == nil {
+			out.SetBool(b)
+		}
+	default:
+		return out, fmt.Errorf("jtree: unsupported map key type: %v", t)
+	}
+	if err != nil {
+		return out, fmt.Errorf("jtree: %w", err)
+	}
+	return out, nil
+}
+
+// decodeInline stores the unmatched object member in the catch-all map field
+func decodeInline(out reflect.Value, field *fieldPlan, key string, elem Node, opt, child *options) error {
+	t := field.Type
+	v := reflect.New(t.Elem()).Elem()
+	if err := decodeChild(elem, v, applyFieldTags(opt.child(child), field.tags, opt)); err != nil {
+		return err
+	}
+	var ptr pendingPtr
+	dest := fieldValue(out, field.StructField, &ptr)
+	if dest.IsNil() {
+		dest.Set(reflect.MakeMap(t))
+	}
+	dest.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), v)
+	ptr.commit(nil, opt)
+	return nil
+}
+
+// fieldValue returns the struct field. Nil embedded pointers on the way are allocated by ptr
+func fieldValue(out reflect.Value, field *StructField, ptr *pendingPtr) reflect.Value {
+	dest := out
+	for i, fi := range field.Index {
+		dest = dest.Field(fi)
+		if i < len(field.Index)-1 && dest.Kind() == reflect.Ptr {
+			// anonymous fields
+			if dest.IsNil() {
+				dest = ptr.alloc(dest)
+			} else {
+				dest = dest.Elem()
+			}
+		}
+	}
+	return dest
+}
+
+// decodeField decodes the member of the object o into the struct field. child is the scratch space for member options
+func decodeField(out reflect.Value, o Object, field *fieldPlan, elem Node, opt, child *options) error {
+	var ptr pendingPtr
+	dest := fieldValue(out, field.StructField, &ptr)
+	err := decodeFieldValue(dest, out.Type(), o, field, elem, opt, child)
+	ptr.commit(err, opt)
+	return err
+}
+
+func decodeFieldValue(dest reflect.Value, t reflect.Type, o Object, field *fieldPlan, elem Node, opt, child *options) error {
+	if name := field.decoder; name != "" {
+		fn := opt.ctx().types().fieldDecoder(name)
+		if fn == nil {
+			return fmt.Errorf("jtree: unknown field decoder '%s': %v", name, t)
+		}
+		return fn(elem, dest, opt.ctx())
+	}
+	applyFieldTags(opt.child(child), field.tags, opt)
+	if disc := field.encodedBy; disc != "" {
+		// the encoding is named by the sibling member
+		switch name := o.FieldByName(disc).(type) {
+		case nil:
+		case String:
+			enc := opt.ctx().encodings().get(string(name))
+			if enc == nil {
+				return fmt.Errorf("jtree: unknown encoding '%s': %v", name, t)
+			}
+			child.enc = enc
 		default:
-			return fmt.Errorf("jtree: struct or map expected: %v", t)
+			return fmt.Errorf("jtree: string encoding name '%s' expected: %v", disc, t)
 		}
 	}
-	return decodeNode(v, o, fn, op...)
+	if disc := field.union; disc != "" {
+		return decodeUnion(dest, o, disc, elem, child)
+	}
+	return decodeChild(elem, dest, child)
 }
 
 // Array represents JSON array
@@ -361,32 +886,135 @@ type Array []Node
 // Type returns the node i.e. "array"
 func (Array) Type() string { return "array" }
 
-// Decode decodes the node into the value pointed by v
+// Append appends elements to the array
+func (a *Array) Append(elems ...Node) {
+	*a = append(*a, elems...)
+}
+
+// Insert inserts elements at i'th position. It panics if i is out of range
+func (a *Array) Insert(i int, elems ...Node) {
+	if i < 0 || i > len(*a) {
+		panic(fmt.Sprintf("jtree: index out of range: %d", i))
+	}
+	*a = append(*a, elems...)
+	copy((*a)[i+len(elems):], (*a)[i:])
+	copy((*a)[i:], elems)
+}
+
+// Remove removes i'th element and returns it. It panics if i is out of range
+func (a *Array) Remove(i int) Node {
+	if i < 0 || i >= len(*a) {
+		panic(fmt.Sprintf("jtree: index out of range: %d", i))
+	}
+	elem := (*a)[i]
+	*a = append((*a)[:i], (*a)[i+1:]...)
+	return elem
+}
+
+// Swap swaps i'th and j'th elements
+func (a Array) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+// Clone returns a deep copy of the array
+func (a Array) Clone() Node {
+	if a == nil {
+		return a
+	}
+	out := make(Array, len(a))
+	for i, elem := range a {
+		out[i] = elem.Clone()
+	}
+	return out
+}
+
+// Decode decodes the node into the value pointed by v. If v is a channel or a function of the form func(T) error, or
+// a pointer to one, elements are decoded and delivered one at a time. Channel sends block, the channel is not closed.
+// A non nil error returned by the function stops decoding and is returned as is
 func (a Array) Decode(v interface{}, op ...Option) error {
-	fn := func(out reflect.Value, opt *options) error {
-		var dst reflect.Value
-		switch out.Kind() {
-		case reflect.Slice:
+	if val := reflect.ValueOf(v); val.Kind() == reflect.Chan || val.Kind() == reflect.Func {
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		v = ptr.Interface()
+	}
+	return decodeNode(v, a, op...)
+}
+
+func (a Array) decodeValue(out reflect.Value, opt *options) error {
+	var dst reflect.Value
+	switch out.Kind() {
+	case reflect.Chan, reflect.Func:
+		return a.stream(out, opt)
+	case reflect.Slice:
+		if opt.ctx().merge && out.Cap() >= len(a) {
+			// reuse the backing array
+			dst = out.Slice(0, len(a))
+			for i := 0; i < dst.Len(); i++ {
+				dst.Index(i).Set(reflect.Zero(dst.Type().Elem()))
+			}
+		} else {
 			dst = reflect.MakeSlice(out.Type(), len(a), len(a))
-		case reflect.Array:
-			dst = out
-		default:
-			return fmt.Errorf("jtree: slice or array expected: %v", out.Type())
 		}
-		for i, elem := range a {
-			if i == dst.Len() {
-				break
-			}
-			if err := elem.Decode(dst.Index(i).Addr().Interface(), mkChildOptions(opt, nil)...); err != nil {
-				return err
+	case reflect.Array:
+		dst = out
+	default:
+		return fmt.Errorf("jtree: slice or array expected: %v", out.Type())
+	}
+	errs := newErrorCollector(opt)
+	set := primitiveSetter(dst, opt)
+	stats := opt.context.statsOf()
+	var child options
+	for i, elem := range a {
+		if i == dst.Len() {
+			break
+		}
+		if set != nil && set(i, elem) {
+			if stats != nil {
+				stats.visit(opt.depth + 1)
 			}
+			continue
 		}
-		if dst != out {
-			out.Set(dst)
+		err := decodeChild(elem, dst.Index(i), opt.child(&child))
+		if !errs.add(strconv.Itoa(i), err) {
+			return errs.err()
 		}
-		return nil
 	}
-	return decodeNode(v, a, fn, op...)
+	if dst != out {
+		out.Set(dst)
+	}
+	return errs.err()
+}
+
+func (a Array) stream(out reflect.Value, opt *options) error {
+	t := out.Type()
+	if out.IsNil() {
+		return fmt.Errorf("jtree: nil %v", t)
+	}
+	var elemType reflect.Type
+	if t.Kind() == reflect.Chan {
+		if t.ChanDir()&reflect.SendDir == 0 {
+			return fmt.Errorf("jtree: receive only channel: %v", t)
+		}
+		elemType = t.Elem()
+	} else {
+		if t.NumIn() != 1 || t.NumOut() != 1 || t.Out(0) != errorType || t.IsVariadic() {
+			return fmt.Errorf("jtree: func(T) error expected: %v", t)
+		}
+		elemType = t.In(0)
+	}
+	var child options
+	for _, elem := range a {
+		dst := reflect.New(elemType).Elem()
+		if err := decodeChild(elem, dst, opt.child(&child)); err != nil {
+			return err
+		}
+		if t.Kind() == reflect.Chan {
+			out.Send(dst)
+		} else if res := out.Call([]reflect.Value{dst}); !res[0].IsNil() {
+			return res[0].Interface().(error)
+		}
+	}
+	return nil
 }
 
 // Bool represents boolean node
@@ -395,31 +1023,41 @@ type Bool bool
 // Type returns the node i.e. "boolean"
 func (Bool) Type() string { return "boolean" }
 
+// Clone returns the node itself
+func (b Bool) Clone() Node { return b }
+
 // Decode decodes the node into the value pointed by v
 func (b Bool) Decode(v interface{}, op ...Option) error {
-	fn := func(out reflect.Value, opt *options) error {
-		k := out.Kind()
-		switch k {
-		case reflect.Bool:
-			out.SetBool(bool(b))
+	return decodeNode(v, b, op...)
+}
 
-		case reflect.String:
-			out.SetString(strconv.FormatBool(bool(b)))
+func (b Bool) decodeValue(out reflect.Value, opt *options) error {
+	k := out.Kind()
+	switch k {
+	case reflect.Bool:
+		out.SetBool(bool(b))
 
-		default:
-			v := 0
-			if b {
-				v = 1
-			}
-			src := reflect.ValueOf(v)
-			if !src.CanConvert(out.Type()) {
-				return fmt.Errorf("jtree: can't convert boolean to %v", out.Type())
-			}
-			out.Set(src.Convert(out.Type()))
+	case reflect.String:
+		if opt.ctx().strictTypes {
+			return fmt.Errorf("jtree: can't convert boolean to %v", out.Type())
 		}
-		return nil
+		out.SetString(strconv.FormatBool(bool(b)))
+
+	default:
+		if opt.ctx().strictTypes {
+			return fmt.Errorf("jtree: can't convert boolean to %v", out.Type())
+		}
+		v := 0
+		if b {
+			v = 1
+		}
+		src := reflect.ValueOf(v)
+		if !src.CanConvert(out.Type()) {
+			return fmt.Errorf("jtree: can't convert boolean to %v", out.Type())
+		}
+		out.Set(src.Convert(out.Type()))
 	}
-	return decodeNode(v, b, fn, op...)
+	return nil
 }
 
 // Null represents null node
@@ -428,17 +1066,78 @@ type Null struct{}
 // Type returns the node i.e. "null"
 func (Null) Type() string { return "null" }
 
+// Clone returns the node itself
+func (n Null) Clone() Node { return n }
+
 // Decode decodes the node into the value pointed by v
 func (n Null) Decode(v interface{}, op ...Option) error {
-	return decodeNode(v, n, nil, op...)
+	return decodeNode(v, n, op...)
+}
+
+// decodeValue is never called as null is handled by decodeInto
+func (n Null) decodeValue(out reflect.Value, opt *options) error {
+	panic("unreachable")
+}
+
+// Raw represents unparsed source text of a JSON value
+type Raw []byte
+
+// RawMessage is the alias of Raw for code ported from encoding/json. Any node decoded into RawMessage
+// is stored as its source text if available or as the compact serialized form otherwise
+type RawMessage = Raw
+
+// Type returns the node i.e. "raw"
+func (Raw) Type() string { return "raw" }
+
+// Clone returns a copy of the source text
+func (r Raw) Clone() Node {
+	if r == nil {
+		return r
+	}
+	out := make(Raw, len(r))
+	copy(out, r)
+	return out
+}
+
+// Parse parses the source text into an AST representation
+func (r Raw) Parse() (Node, error) {
+	return NewParser(bytes.NewReader(r)).Parse()
+}
+
+// Decode parses the source text and decodes the resulting node into the value pointed by v.
+// If v is a pointer to Node then the raw node itself is stored, if v is a pointer to Raw or json.RawMessage
+// then the copy of the source text is stored
+func (r Raw) Decode(v interface{}, op ...Option) error {
+	switch p := v.(type) {
+	case *Node:
+		*p = r
+		return nil
+	case *Raw:
+		*p = append(Raw(nil), r...)
+		return nil
+	case *json.RawMessage:
+		*p = append(json.RawMessage(nil), r...)
+		return nil
+	}
+	n, err := r.Parse()
+	if err != nil {
+		return err
+	}
+	return n.Decode(v, op...)
 }
 
 var (
 	nodeType            = reflect.TypeOf((*Node)(nil)).Elem()
 	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
 	bigIntType          = reflect.TypeOf((*big.Int)(nil)).Elem()
 	bigFloatType        = reflect.TypeOf((*big.Float)(nil)).Elem()
 	timeType            = reflect.TypeOf((*time.Time)(nil)).Elem()
+	jsonNumberType      = reflect.TypeOf(json.Number(""))
+	numberType          = reflect.TypeOf(Number(""))
+	durationType        = reflect.TypeOf(time.Duration(0))
+	rawType             = reflect.TypeOf(Raw(nil))
+	jsonRawMessageType  = reflect.TypeOf(json.RawMessage(nil))
 	emptyType           = reflect.TypeOf((*interface{})(nil)).Elem()
 	errorType           = reflect.TypeOf((*error)(nil)).Elem()
 	float64Type         = reflect.TypeOf(float64(0))
@@ -447,11 +1146,42 @@ var (
 	objectType          = reflect.MapOf(stringType, emptyType)
 	arrayType           = reflect.SliceOf(emptyType)
 	decoderType         = reflect.TypeOf((*JSONDecoder)(nil)).Elem()
+	ctxDecoderType      = reflect.TypeOf((*JSONContextDecoder)(nil)).Elem()
 )
 
-type decodeFunc func(out reflect.Value, opt *options) error
+// nativeTypes are decoded by the package itself even though they implement json.Unmarshaler
+var nativeTypes = map[reflect.Type]bool{
+	bigIntType:   true,
+	bigFloatType: true,
+	timeType:     true,
+}
+
+func nullable(k reflect.Kind) bool {
+	return k == reflect.Ptr || k == reflect.Interface || k == reflect.Map || k == reflect.Slice ||
+		k == reflect.Chan || k == reflect.Func
+}
+
+// DecodeValue decodes the node into the settable value or into the value pointed by the non nil pointer out
+func DecodeValue(n Node, out reflect.Value, op ...Option) error {
+	switch {
+	case out.CanSet():
+		return n.Decode(out.Addr().Interface(), op...)
+	case out.Kind() == reflect.Ptr && !out.IsNil():
+		return n.Decode(out.Interface(), op...)
+	case !out.IsValid():
+		return errors.New("jtree: invalid value")
+	default:
+		return fmt.Errorf("jtree: value is not settable: %v", out.Type())
+	}
+}
+
+// valueDecoder is implemented by built-in nodes decoding directly into reflect.Value
+type valueDecoder interface {
+	Node
+	decodeValue(out reflect.Value, opt *options) error
+}
 
-func decodeNode(v interface{}, node Node, decode decodeFunc, op ...Option) error {
+func decodeNode(v interface{}, node valueDecoder, op ...Option) error {
 	opt := new(options).apply(op)
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Ptr {
@@ -460,23 +1190,133 @@ func decodeNode(v interface{}, node Node, decode decodeFunc, op ...Option) error
 	if val.IsNil() {
 		return errors.New("jtree: nil pointer")
 	}
-	out := val.Elem()
+	if s := opt.startStats(); s != nil {
+		defer s.end()
+	}
+	return decodeInto(node, val.Elem(), opt)
+}
+
+// decodeChild decodes the member or the element into the addressable value dest with options prepared by child
+func decodeChild(node Node, dest reflect.Value, opt *options) error {
+	switch node.(type) {
+	case *Num, NaN, String, Object, Array, Bool, Null:
+		// user types embedding built-in nodes must not be caught here
+		return decodeInto(node.(valueDecoder), dest, opt)
+	}
+	return node.Decode(dest.Addr().Interface(), opCopy(opt))
+}
+
+// decodeInto decodes the node into the addressable value out
+func decodeInto(node valueDecoder, out reflect.Value, opt *options) error {
+	if opt.context != nil && opt.context.cancel != nil {
+		if err := opt.context.cancel.check(); err != nil {
+			return err
+		}
+	}
+	if max := opt.ctx().maxDecodeDepth(); max > 0 && opt.depth > max {
+		return fmt.Errorf("jtree: maximum decode depth of %d exceeded", max)
+	}
+	if s := opt.context.statsOf(); s != nil {
+		s.visit(opt.depth)
+	}
+	if reflect.PtrTo(out.Type()).Implements(optionalDecoderType) {
+		return out.Addr().Interface().(optionalDecoder).decodeOptional(node, opt)
+	}
+	if ok, err := applyHooks(node, out, opt); ok {
+		return err
+	}
+	if t := out.Type(); t == rawType || t == jsonRawMessageType {
+		// compact text of the parsed node
+		b, err := appendNode(nil, node)
+		if err != nil {
+			return err
+		}
+		out.SetBytes(b)
+		return nil
+	}
 	if _, ok := node.(Null); ok {
 		// special case
+		if opt.notNull || opt.ctx().noNull && !nullable(out.Kind()) {
+			return fmt.Errorf("jtree: null is not allowed: %v", out.Type())
+		}
+		if fn := opt.ctx().types().nullHandler(out.Type()); fn != nil {
+			v, err := fn(opt.ctx())
+			if err != nil {
+				return err
+			}
+			out.Set(v)
+			return nil
+		}
 		out.Set(reflect.Zero(out.Type()))
 		return nil
 	}
 
 	// out must be a non pointer
-	for out.Kind() == reflect.Ptr {
-		if out.IsNil() {
-			out.Set(reflect.New(out.Type().Elem()))
+	if out.Kind() == reflect.Ptr {
+		var ptr pendingPtr
+		for out.Kind() == reflect.Ptr {
+			if out.IsNil() {
+				out = ptr.alloc(out)
+			} else {
+				out = out.Elem()
+			}
 		}
-		out = out.Elem()
+		err := applyHooksOrDecode(node, out, opt)
+		ptr.commit(err, opt)
+		return err
+	}
+	return decodeResolved(node, out, opt)
+}
+
+// pendingPtr is the first nil pointer met on the way to the destination and the value allocated for it.
+// The value is stored only after it's written so failed decodes leave pointers untouched
+type pendingPtr struct {
+	slot reflect.Value
+	val  reflect.Value
+}
+
+// alloc allocates the value for the nil pointer slot returning the pointed value
+func (p *pendingPtr) alloc(slot reflect.Value) reflect.Value {
+	v := reflect.New(slot.Type().Elem())
+	if p.slot.IsValid() {
+		// already detached
+		slot.Set(v)
+	} else {
+		p.slot, p.val = slot, v
+	}
+	return v.Elem()
+}
+
+// commit stores the allocated value on success. Partially decoded values are kept when errors are collected
+func (p *pendingPtr) commit(err error, opt *options) {
+	if p.slot.IsValid() && (err == nil || opt.ctx().collect) {
+		p.slot.Set(p.val)
 	}
+}
 
+func applyHooksOrDecode(node valueDecoder, out reflect.Value, opt *options) error {
+	if ok, err := applyHooks(node, out, opt); ok {
+		return err
+	}
+	return decodeResolved(node, out, opt)
+}
+
+// decodeResolved decodes the node into the non pointer value out
+func decodeResolved(node valueDecoder, out reflect.Value, opt *options) error {
 	// concrete type
 	if out.Kind() != reflect.Interface {
+		val, err := opt.ctx().types().call(out.Type(), node, opt)
+		if err != nil {
+			return err
+		}
+		if val.IsValid() {
+			out.Set(val)
+			return nil
+		}
+		if reflect.PtrTo(out.Type()).Implements(ctxDecoderType) && out.CanAddr() {
+			dec := out.Addr().Interface().(JSONContextDecoder)
+			return dec.DecodeJSONContext(node, opt.ctx())
+		}
 		if reflect.PtrTo(out.Type()).Implements(decoderType) && out.CanAddr() {
 			dec := out.Addr().Interface().(JSONDecoder)
 			if err := dec.DecodeJSON(node); err != nil {
@@ -484,7 +1324,31 @@ func decodeNode(v interface{}, node Node, decode decodeFunc, op ...Option) error
 			}
 			return nil
 		}
-		return decode(out, opt)
+		if opt.ctx().proto {
+			// the adapter may adjust options of this value only
+			cp := *opt
+			cp.ownCtx = false
+			if ok, err := protoAdapter(node, out, &cp); ok {
+				return err
+			}
+			opt = &cp
+		}
+		if t := out.Type(); reflect.PtrTo(t).Implements(jsonUnmarshalerType) && !nativeTypes[t] && out.CanAddr() {
+			// serialize the node for third party types
+			dec := out.Addr().Interface().(json.Unmarshaler)
+			b, err := appendNode(nil, node)
+			if err != nil {
+				return err
+			}
+			if err := dec.UnmarshalJSON(b); err != nil {
+				return fmt.Errorf("jtree: %w", err)
+			}
+			return nil
+		}
+		if n, ok := opt.ctx().types().coerce(node, out.Type()); ok {
+			return n.Decode(out.Addr().Interface(), opCopy(opt))
+		}
+		return node.decodeValue(out, opt)
 	}
 
 	if out.Type() == nodeType {
@@ -494,7 +1358,7 @@ func decodeNode(v interface{}, node Node, decode decodeFunc, op ...Option) error
 	}
 
 	// user interface type
-	val, err := opt.ctx().types().call(out.Type(), node, opt.context)
+	val, err := opt.ctx().types().call(out.Type(), node, opt)
 	if err != nil {
 		return err
 	}
@@ -502,12 +1366,34 @@ func decodeNode(v interface{}, node Node, decode decodeFunc, op ...Option) error
 		out.Set(val)
 		return nil
 	}
+	if val, err = opt.ctx().types().callFallback(out.Type(), node, opt.ctx()); err != nil {
+		return err
+	}
+	if val.IsValid() {
+		out.Set(val)
+		return nil
+	}
 
 	// allocate default type
 	var dst reflect.Value
 	switch node.(type) {
-	case *Num:
+	case NaN:
 		dst = reflect.New(float64Type).Elem()
+	case *Num:
+		switch opt.ctx().numbers {
+		case NumberJSON:
+			dst = reflect.New(jsonNumberType).Elem()
+		case NumberText:
+			dst = reflect.New(numberType).Elem()
+		case NumberNode:
+			if !reflect.TypeOf(node).AssignableTo(out.Type()) {
+				return fmt.Errorf("jtree: can't convert %v to %v", reflect.TypeOf(node), out.Type())
+			}
+			out.Set(reflect.ValueOf(node))
+			return nil
+		default:
+			dst = reflect.New(float64Type).Elem()
+		}
 	case String:
 		dst = reflect.New(stringType).Elem()
 	case Object:
@@ -519,7 +1405,7 @@ func decodeNode(v interface{}, node Node, decode decodeFunc, op ...Option) error
 	default:
 		panic("unknown node")
 	}
-	if err := decode(dst, opt); err != nil {
+	if err := node.decodeValue(dst, opt); err != nil {
 		return err
 	}
 	if !dst.CanConvert(out.Type()) {
@@ -529,11 +1415,16 @@ func decodeNode(v interface{}, node Node, decode decodeFunc, op ...Option) error
 	return nil
 }
 
-func mkChildOptions(opt *options, fopt []Option) []Option {
-	out := make([]Option, 0, len(fopt)+2)
-	if opt.elem != nil {
-		out = append(out, opInit(opt.elem))
+// child initializes dst with options of the member or the element of the value decoded with o and returns it.
+// Containers reuse the same dst for all children as nested decodes complete before the next child starts
+func (o *options) child(dst *options) *options {
+	if o.elem != nil {
+		*dst = *o.elem
+		dst.elem = nil
+	} else {
+		*dst = options{}
 	}
-	out = append(out, OpCtx(opt.context))
-	return append(out, fopt...)
+	dst.context, dst.ownCtx = o.context, false
+	dst.depth = o.depth + 1
+	return dst
 }
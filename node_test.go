@@ -0,0 +1,135 @@
+package jtree_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+	src := mustParse(t, `{"a": [1, "x", {"b": null}], "c": true}`).(jtree.Object)
+	src = append(src, &jtree.Field{Key: "raw", Value: jtree.Raw(`[1]`)})
+	clone := src.Clone().(jtree.Object)
+	assert.Equal(t, src, clone)
+
+	clone[0].Value.(jtree.Array)[2].(jtree.Object)[0].Value = jtree.Bool(false)
+	(*big.Float)(clone[0].Value.(jtree.Array)[0].(*jtree.Num)).SetInt64(2)
+	clone[2].Value.(jtree.Raw)[1] = '2'
+	clone[1].Key = "d"
+
+	assert.Equal(t, mustParse(t, `{"a": [1, "x", {"b": null}], "c": true}`), src[:2])
+	assert.Equal(t, jtree.Raw(`[1]`), src[2].Value)
+}
+
+func TestObjectMutation(t *testing.T) {
+	o := mustParse(t, `{"a": 1, "b": 2, "c": 3}`).(jtree.Object)
+
+	o.Set("b", jtree.String("x"))
+	o.Set("d", jtree.Bool(true))
+	assert.Equal(t, mustParse(t, `{"a": 1, "b": "x", "c": 3, "d": true}`), o)
+
+	assert.True(t, o.Delete("a"))
+	assert.False(t, o.Delete("a"))
+	assert.Equal(t, mustParse(t, `{"b": "x", "c": 3, "d": true}`), o)
+
+	o.InsertAt(0, "z", jtree.Null{})
+	o.InsertAt(4, "e", jtree.Null{})
+	o.InsertAt(4, "b", jtree.Null{})
+	assert.Equal(t, mustParse(t, `{"z": null, "c": 3, "d": true, "b": null, "e": null}`), o)
+	assert.Panics(t, func() { o.InsertAt(6, "f", jtree.Null{}) })
+
+	assert.True(t, o.Rename("c", "cc"))
+	assert.True(t, o.Rename("d", "e"))
+	assert.False(t, o.Rename("x", "y"))
+	assert.Equal(t, mustParse(t, `{"z": null, "cc": 3, "e": true, "b": null}`), o)
+}
+
+func TestArrayMutation(t *testing.T) {
+	a := mustParse(t, `[1, 2, 3]`).(jtree.Array)
+
+	a.Append(jtree.String("x"), jtree.String("y"))
+	assert.Equal(t, mustParse(t, `[1, 2, 3, "x", "y"]`), a)
+
+	a.Insert(1, jtree.Bool(true), jtree.Null{})
+	a.Insert(7, jtree.Bool(false))
+	assert.Equal(t, mustParse(t, `[1, true, null, 2, 3, "x", "y", false]`), a)
+	assert.Panics(t, func() { a.Insert(9, jtree.Null{}) })
+
+	assert.Equal(t, jtree.Null{}, a.Remove(2))
+	assert.Equal(t, jtree.Bool(false), a.Remove(6))
+	assert.Panics(t, func() { a.Remove(6) })
+	assert.Equal(t, mustParse(t, `[1, true, 2, 3, "x", "y"]`), a)
+
+	a.Swap(0, 5)
+	assert.Equal(t, mustParse(t, `["y", true, 2, 3, "x", 1]`), a)
+}
+
+func TestObjectIndex(t *testing.T) {
+	x := mustParse(t, `{"a": 1, "b": 2, "a": 3, "c": 4}`).(jtree.Object).Index()
+
+	v, ok := x.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, newNumNode("1"), v)
+	_, ok = x.Get("d")
+	assert.False(t, ok)
+
+	x.Set("b", jtree.String("x"))
+	x.Set("d", jtree.Null{})
+	assert.True(t, x.Delete("a"))
+	assert.False(t, x.Delete("e"))
+
+	// the duplicate becomes visible
+	v, ok = x.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, newNumNode("3"), v)
+	v, _ = x.Get("d")
+	assert.Equal(t, jtree.Null{}, v)
+	assert.Equal(t, 4, x.Len())
+	assert.Equal(t, mustParse(t, `{"b": "x", "a": 3, "c": 4, "d": null}`), x.Object())
+	assert.True(t, x.Delete("b"))
+	v, _ = x.Get("c")
+	assert.Equal(t, newNumNode("4"), v)
+	assert.True(t, x.Delete("a"))
+	_, ok = x.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, mustParse(t, `{"c": 4, "d": null}`), x.Object())
+
+	// the index doesn't share the backing array with its source and result
+	src := make(jtree.Object, 1, 4)
+	src[0] = &jtree.Field{Key: "a", Value: jtree.Null{}}
+	x = src.Index()
+	x.Set("b", jtree.Bool(true))
+	out := append(x.Object(), &jtree.Field{Key: "c", Value: jtree.Null{}})
+	_ = append(src, &jtree.Field{Key: "x", Value: jtree.Null{}})
+	x.Set("d", jtree.Bool(false))
+	assert.Equal(t, jtree.Object{{"a", jtree.Null{}}, {"b", jtree.Bool(true)}, {"c", jtree.Null{}}}, out)
+	assert.Equal(t, jtree.Object{{"a", jtree.Null{}}, {"b", jtree.Bool(true)}, {"d", jtree.Bool(false)}}, x.Object())
+}
+
+func TestNumAccessors(t *testing.T) {
+	n := newNumNode("-42")
+	assert.True(t, n.IsInt())
+	i, ok := n.Int64()
+	assert.True(t, ok)
+	assert.Equal(t, int64(-42), i)
+
+	n = newNumNode("1.5")
+	assert.False(t, n.IsInt())
+	_, ok = n.Int64()
+	assert.False(t, ok)
+	f, ok := n.Float64()
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, f)
+
+	_, ok = newNumNode("1e19").Int64()
+	assert.False(t, ok)
+	_, ok = newNumNode("1e400").Float64()
+	assert.False(t, ok)
+	_, ok = newNumNode("1e-400").Float64()
+	assert.False(t, ok)
+	f, ok = newNumNode("0").Float64()
+	assert.True(t, ok)
+	assert.Equal(t, float64(0), f)
+}
@@ -0,0 +1,102 @@
+package jtree
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Number is the textual representation of a JSON number. It's used for numbers decoded into empty interface values
+// with NumberText mode and can be a decoding destination itself
+type Number string
+
+// String returns the literal text of the number
+func (n Number) String() string { return string(n) }
+
+// Int64 returns the number as an integer
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Float64 returns the number as a floating point value
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// BigInt returns the number as an arbitrary precision integer
+func (n Number) BigInt() (*big.Int, error) {
+	i, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		return nil, fmt.Errorf("jtree: invalid integer: %s", string(n))
+	}
+	return i, nil
+}
+
+// setNumber initializes the number node from its literal. Integers fitting into int64 bypass the generic
+// big.Float parser producing the same value and precision. Negative zero takes the generic path to keep the sign
+func setNumber(n *Num, s string) error {
+	if i, ok := smallInt(s); ok {
+		(*big.Float)(n).SetInt64(i)
+		return nil
+	}
+	_, _, err := (*big.Float)(n).Parse(s, 10)
+	return err
+}
+
+// smallInt returns the value of the integer literal fitting into int64 excluding negative zero
+func smallInt(s string) (int64, bool) {
+	if !isSmallInt(s) {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i, err == nil && (i != 0 || s[0] != '-')
+}
+
+func isSmallInt(s string) bool {
+	if len(s) != 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) == 0 || len(s) > 18 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// int64Value returns the truncated value of the number and true if it's within int64 range.
+// Values near the range boundaries fall back to big.Int conversion
+func (n *Num) int64Value() (int64, bool) {
+	f := (*big.Float)(n)
+	if f.IsInf() {
+		return 0, false
+	}
+	if v, _ := f.Int64(); v != math.MinInt64 && v != math.MaxInt64 {
+		return v, true
+	}
+	i, _ := f.Int(nil)
+	if !i.IsInt64() {
+		return 0, false
+	}
+	return i.Int64(), true
+}
+
+// uint64Value is the uint64 counterpart of int64Value
+func (n *Num) uint64Value() (uint64, bool) {
+	f := (*big.Float)(n)
+	if f.IsInf() {
+		return 0, false
+	}
+	if v, _ := f.Uint64(); v != 0 && v != math.MaxUint64 {
+		return v, true
+	}
+	i, _ := f.Int(nil)
+	if !i.IsUint64() {
+		return 0, false
+	}
+	return i.Uint64(), true
+}
@@ -0,0 +1,31 @@
+package jtree
+
+import "reflect"
+
+// Optional is the field type which records whether the object key was absent, was null or carried a value
+type Optional[T any] struct {
+	Value   T
+	Present bool // the key was present
+	Null    bool // the value was null
+}
+
+// Get returns the value and true if the key was present and wasn't null
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Present && !o.Null
+}
+
+type optionalDecoder interface {
+	decodeOptional(node Node, opt *options) error
+}
+
+var optionalDecoderType = reflect.TypeOf((*optionalDecoder)(nil)).Elem()
+
+func (o *Optional[T]) decodeOptional(node Node, opt *options) error {
+	o.Present = true
+	var zero T
+	o.Value = zero
+	if _, o.Null = node.(Null); o.Null {
+		return nil
+	}
+	return node.Decode(&o.Value, opCopy(opt))
+}
@@ -1,122 +1,560 @@
 package jtree
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
+// SyntaxError is the positioned JSON syntax error
+type SyntaxError struct {
+	Offset int64 // position of the offending character
+	msg    string
+	err    error
+}
+
+func (e *SyntaxError) Error() string { return e.msg }
+
+// Unwrap returns the underlying error if any
+func (e *SyntaxError) Unwrap() error { return e.err }
+
+func syntaxErrorf(pos int64, format string, a ...interface{}) *SyntaxError {
+	err := fmt.Errorf(format, a...)
+	return &SyntaxError{Offset: pos, msg: err.Error(), err: errors.Unwrap(err)}
+}
+
+// ErrorList is the list of syntax errors returned in the error recovery mode
+type ErrorList []*SyntaxError
+
+func (e ErrorList) Error() string {
+	switch len(e) {
+	case 0:
+		return "jtree: no errors"
+	case 1:
+		return e[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", e[0].Error(), len(e)-1)
+	}
+}
+
+type parserOptions struct {
+	maxKeyLen    int
+	keyValidator func(key string) error
+	shallow      int
+	recover      bool
+	skip         func(path []string) bool
+	raw          func(path []string) bool
+	comments     bool
+	tee          func(raw []byte)
+	trace        func(tok Token)
+
+	noTrailingCommas bool
+	strictEscapes    bool
+	strictNumbers    bool
+	noControlChars   bool
+	nonFinite        bool
+	alloc            NodeAllocator
+	stats            func(ParseStats)
+	singleQuotes     bool
+	progress         func(Progress) error
+	progressInterval int64
+}
+
+// ParserOption is the function pointer used to pass options to NewParser
+type ParserOption func(*parserOptions)
+
+// OpMaxKeyLength limits the length of object keys in bytes. Zero means no limit
+func OpMaxKeyLength(n int) ParserOption { return func(o *parserOptions) { o.maxKeyLen = n } }
+
+// OpKeyValidator sets the function used to validate every object key. A non nil error returned by the function
+// aborts parsing and is reported along with the key position
+func OpKeyValidator(fn func(key string) error) ParserOption {
+	return func(o *parserOptions) { o.keyValidator = fn }
+}
+
+// OpShallow makes the parser to store objects and arrays nested deeper than depth levels as Raw nodes containing
+// the unparsed source text. The top level value has the depth of zero. Zero means no limit
+func OpShallow(depth int) ParserOption { return func(o *parserOptions) { o.shallow = depth } }
+
+// OpRecover enables the error recovery mode. On a syntax error the parser skips to the next separator or closing
+// delimiter and continues. Parse returns the best effort tree along with ErrorList
+func OpRecover(o *parserOptions) { o.recover = true }
+
+// OpSkipFunc sets the function called for every object member and array element with the path to the value
+// consisting of object keys and decimal array indices. If the function returns true the value is skipped without
+// building nodes and omitted from the resulting tree. The function must not retain the path slice
+func OpSkipFunc(fn func(path []string) bool) ParserOption {
+	return func(o *parserOptions) { o.skip = fn }
+}
+
+// OpSkipPaths makes the parser to skip values located by slash separated paths like "/data/0/blob".
+// "~1" and "~0" escape "/" and "~" inside keys respectively. The "*" path element matches any key or index
+func OpSkipPaths(paths ...string) ParserOption {
+	return OpSkipFunc(pathMatcher(paths))
+}
+
+// OpRawFunc makes the parser to store values for which fn returns true as Raw nodes containing their exact source text.
+// See OpSkipFunc for the path format
+func OpRawFunc(fn func(path []string) bool) ParserOption {
+	return func(o *parserOptions) { o.raw = fn }
+}
+
+// OpRawPaths makes the parser to store values located by slash separated paths as Raw nodes containing their exact
+// source text. See OpSkipPaths for the path format
+func OpRawPaths(paths ...string) ParserOption {
+	return OpRawFunc(pathMatcher(paths))
+}
+
+func pathMatcher(paths []string) func(path []string) bool {
+	split := make([][]string, len(paths))
+	for i, p := range paths {
+		split[i] = splitPath(p)
+	}
+	return func(path []string) bool {
+	next:
+		for _, p := range split {
+			if len(p) != len(path) {
+				continue
+			}
+			for i, elem := range p {
+				if elem != "*" && elem != path[i] {
+					continue next
+				}
+			}
+			return true
+		}
+		return false
+	}
+}
+
+func splitPath(p string) []string {
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return []string{}
+	}
+	out := strings.Split(p, "/")
+	r := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, s := range out {
+		out[i] = r.Replace(s)
+	}
+	return out
+}
+
+// OpTee sets the function receiving the exact source text of every top level value parsed by Parse,
+// excluding surrounding whitespace. The slice may be retained by the function
+func OpTee(fn func(raw []byte)) ParserOption { return func(o *parserOptions) { o.tee = fn } }
+
+// OpNoTrailingCommas makes the parser to reject trailing commas in arrays and objects
+func OpNoTrailingCommas(o *parserOptions) { o.noTrailingCommas = true }
+
+// OpStrictEscapes makes the parser to reject escape sequences not defined by RFC 8259 like \x41 or \z
+func OpStrictEscapes(o *parserOptions) { o.strictEscapes = true }
+
+// OpStrictNumbers makes the parser to reject numbers not matching RFC 8259 grammar like ".5", "01" or "1."
+func OpStrictNumbers(o *parserOptions) { o.strictNumbers = true }
+
+// OpNoControlChars makes the parser to reject unescaped control characters inside strings
+func OpNoControlChars(o *parserOptions) { o.noControlChars = true }
+
+// OpAllowNonFinite makes the parser to accept NaN, Infinity and -Infinity literals. Infinities are stored as
+// infinite Num values and NaN is stored as NaN node
+func OpAllowNonFinite(o *parserOptions) { o.nonFinite = true }
+
+// OpAllowSingleQuotes makes the lexer to accept single quoted strings like 'abc' with the same escape handling
+// as double quoted ones. \' is a valid escape sequence in this mode
+func OpAllowSingleQuotes(o *parserOptions) { o.singleQuotes = true }
+
+// OpStrict is the conformance configuration disabling all lenient behaviors. Combined with Parser.ExpectEOF
+// (used by Unmarshal) the parser accepts RFC 8259 compliant documents only, as required by JSONTestSuite
+// y_ and n_ test cases
+func OpStrict(o *parserOptions) {
+	OpNoTrailingCommas(o)
+	OpStrictEscapes(o)
+	OpStrictNumbers(o)
+	OpNoControlChars(o)
+}
+
+// ParserBehavior reports optional lenient behaviors of the parser
+type ParserBehavior struct {
+	TrailingCommas bool // trailing commas in arrays and objects are accepted
+	LooseEscapes   bool // \xXX and unknown escape sequences are accepted
+	LooseNumbers   bool // numbers not matching RFC 8259 grammar are accepted
+	ControlChars   bool // unescaped control characters inside strings are accepted
+	NonFinite      bool // NaN, Infinity and -Infinity literals are accepted
+	SingleQuotes   bool // single quoted strings are accepted
+	Comments       bool // comments are accepted
+}
+
+// Behavior returns the set of optional behaviors enabled by the parser options.
+// Behavior(OpStrict) returns the zero value
+func Behavior(op ...ParserOption) ParserBehavior {
+	var o parserOptions
+	for _, fn := range op {
+		fn(&o)
+	}
+	return ParserBehavior{
+		TrailingCommas: !o.noTrailingCommas,
+		LooseEscapes:   !o.strictEscapes,
+		LooseNumbers:   !o.strictNumbers,
+		ControlChars:   !o.noControlChars,
+		NonFinite:      o.nonFinite,
+		SingleQuotes:   o.singleQuotes,
+		Comments:       o.comments,
+	}
+}
+
+// validNumber checks the number against RFC 8259 grammar
+func validNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		n := 0
+		for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			n++
+		}
+		return n
+	}
+	if i < len(s) && s[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// Progress is reported periodically during parsing
+type Progress struct {
+	Bytes int64 // bytes consumed from the input
+	Nodes int64 // nodes created
+}
+
+// OpProgress sets the function called after every interval bytes consumed and at the end of every Parse call.
+// A non nil error returned by the function aborts parsing. It can be used to display progress or to enforce time budgets
+func OpProgress(interval int64, fn func(Progress) error) ParserOption {
+	return func(o *parserOptions) {
+		o.progress = fn
+		o.progressInterval = interval
+	}
+}
+
+// NoControlChars is a key validator which rejects keys containing control characters
+func NoControlChars(key string) error {
+	for _, c := range key {
+		if unicode.IsControl(c) {
+			return fmt.Errorf("control character %U", c)
+		}
+	}
+	return nil
+}
+
 // Parser parses JSON stream into an AST representation
 type Parser struct {
-	r *reader
+	r          *reader
+	opt        parserOptions
+	depth      int
+	path       []string
+	tok        token // pushed back token
+	errs       ErrorList
+	nodes      int64
+	nextReport int64
+	comments   []Comment
+	leading    []rawComment
+	last       []string // path of the last parsed value, nil if followed by a comment on the next line
+	cancel     *canceler
+	maxDepth   int       // maximum container nesting reached by the current Parse call
+	num        big.Float // scratch value of numbers decoded directly
 }
 
 // NewParser returns new Parser
-func NewParser(r io.RuneReader) *Parser {
-	return &Parser{r: newReader(r)}
+func NewParser(r io.RuneReader, op ...ParserOption) *Parser {
+	return newParser(newReader(r), op)
+}
+
+func newParser(r *reader, op []ParserOption) *Parser {
+	p := Parser{r: r}
+	for _, fn := range op {
+		fn(&p.opt)
+	}
+	if p.opt.alloc == nil {
+		p.opt.alloc = HeapAllocator
+	}
+	r.trace = p.opt.trace
+	r.opt = &p.opt
+	p.nextReport = p.opt.progressInterval
+	return &p
+}
+
+// reset prepares the parser for the new input keeping options and scratch buffers
+func (p *Parser) reset(src io.RuneReader) {
+	p.r.reset(src)
+	*p = Parser{
+		r:          p.r,
+		opt:        p.opt,
+		path:       p.path[:0],
+		nextReport: p.opt.progressInterval,
+	}
+}
+
+func (p *Parser) checkKey(key token) error {
+	if p.opt.maxKeyLen > 0 && len(key.str) > p.opt.maxKeyLen {
+		return syntaxErrorf(key.p, "jtree: object key exceeds maximum length of %d bytes at position %d", p.opt.maxKeyLen, key.p)
+	}
+	if p.opt.keyValidator != nil {
+		if err := p.opt.keyValidator(key.str); err != nil {
+			return syntaxErrorf(key.p, "jtree: invalid object key at position %d: %w", key.p, err)
+		}
+	}
+	return nil
 }
 
 func (p *Parser) parseArray() (Array, error) {
+	p.enter()
+	defer p.leave()
 	array := make(Array, 0)
 	more := true
+	comma := false
+	index := 0
 	for {
-		tok, err := p.r.token()
-		if err != nil {
-			return nil, err
-		}
-		if more {
-			if del, ok := tok.(tokDelim); ok && del.ch == ']' {
-				break
-			}
-			n, err := p.parse(tok)
-			if err != nil {
-				return nil, err
+		tok, err := p.next()
+		if err == nil {
+			if more {
+				if tok.delim(']') && !(comma && p.opt.noTrailingCommas) {
+					break
+				}
+				var (
+					n    Node
+					name string
+				)
+				if p.tracksPath() {
+					name = strconv.Itoa(index)
+				}
+				index++
+				n, err = p.parseElem(name, tok)
+				if n != nil {
+					array = append(array, n)
+				}
+				if err == nil {
+					more, comma = false, false
+					continue
+				}
+			} else {
+				if !tok.delim(',') && !tok.delim(']') {
+					err = syntaxErrorf(tok.pos(), "jtree: unexpected token at position %d: '%v'", tok.pos(), tok)
+				} else if tok.ch == ']' {
+					break
+				} else {
+					more, comma = true, true
+					continue
+				}
 			}
-			array = append(array, n)
-			more = false
 		} else {
-			if del, ok := tok.(tokDelim); !ok || del.ch != ',' && del.ch != ']' {
-				return nil, fmt.Errorf("jtree: unexpected token at position %d: '%v'", tok.pos(), tok)
-			} else if del.ch == ']' {
-				break
-			} else {
-				more = true
+			tok = token{}
+		}
+		del, err := p.resync(err, tok, ']')
+		if err != nil {
+			if p.opt.recover {
+				return array, err
 			}
+			return nil, err
+		}
+		if del == ']' {
+			break
 		}
+		more = true
 	}
 	return array, nil
 }
 
+// tracksPath returns true if the parser maintains the current element path
+func (p *Parser) tracksPath() bool {
+	return p.opt.skip != nil || p.opt.raw != nil || p.opt.comments
+}
+
+// parseElem parses the container element or skips it returning nil node
+func (p *Parser) parseElem(name string, tok token) (Node, error) {
+	if !p.tracksPath() {
+		return p.parse(tok)
+	}
+	p.path = append(p.path, name)
+	defer func() { p.path = p.path[:len(p.path)-1] }()
+	if p.opt.skip != nil && p.opt.skip(p.path) {
+		return nil, p.skip(tok)
+	}
+	if p.opt.comments {
+		p.leadingComments()
+		defer p.endComments()
+	}
+	if p.opt.raw != nil && p.opt.raw(p.path) {
+		return p.parseRawValue(tok)
+	}
+	return p.parse(tok)
+}
+
+func (p *Parser) parseField(tok token) (*Field, token, error) {
+	key := tok
+	if key.kind != tokString {
+		if tok.kind == tokDelim {
+			return nil, tok, syntaxErrorf(tok.pos(), "jtree: unexpected delimiter '%c' at position %d", tok.ch, tok.pos())
+		}
+		return nil, tok, syntaxErrorf(tok.pos(), "jtree: object key expected at position %d: '%v'", tok.pos(), tok)
+	}
+	if err := p.checkKey(key); err != nil {
+		return nil, token{}, err
+	}
+	tok, err := p.next()
+	if err != nil {
+		return nil, token{}, err
+	}
+	if !tok.delim(':') {
+		return nil, tok, syntaxErrorf(tok.pos(), "jtree: colon expected at position %d: '%v'", tok.pos(), tok)
+	}
+	if tok, err = p.next(); err != nil {
+		return nil, token{}, err
+	}
+	value, err := p.parseElem(key.str, tok)
+	if value != nil {
+		return p.opt.alloc.NewField(key.str, value), tok, err
+	}
+	return nil, tok, err
+}
+
 func (p *Parser) parseObject() (Object, error) {
+	p.enter()
+	defer p.leave()
 	object := make(Object, 0)
 	more := true
+	comma := false
 	for {
-		tok, err := p.r.token()
-		if err != nil {
-			return nil, err
-		}
-		if more {
-			if del, ok := tok.(tokDelim); ok {
-				if del.ch == '}' {
+		tok, err := p.next()
+		if err == nil {
+			if more {
+				if tok.delim('}') && !(comma && p.opt.noTrailingCommas) {
 					break
-				} else {
-					return nil, fmt.Errorf("jtree: unexpected delimiter '%c' at position %d", del.ch, tok.pos())
-				}
-			} else {
-				key, ok := tok.(tokString)
-				if !ok {
-					return nil, fmt.Errorf("jtree: object key expected at position %d: '%v'", tok.pos(), tok)
 				}
-				tok, err = p.r.token()
-				if err != nil {
-					return nil, err
+				var field *Field
+				field, tok, err = p.parseField(tok)
+				if field != nil {
+					object = append(object, field)
 				}
-				del, ok := tok.(tokDelim)
-				if !ok || del.ch != ':' {
-					return nil, fmt.Errorf("jtree: colon expected at position %d: '%v'", tok.pos(), tok)
+				if err == nil {
+					more, comma = false, false
+					continue
 				}
-				tok, err = p.r.token()
-				if err != nil {
-					return nil, err
-				}
-				value, err := p.parse(tok)
-				if err != nil {
-					return nil, err
+			} else {
+				if !tok.delim(',') && !tok.delim('}') {
+					err = syntaxErrorf(tok.pos(), "jtree: unexpected token at position %d: '%v'", tok.pos(), tok)
+				} else if tok.ch == '}' {
+					break
+				} else {
+					more, comma = true, true
+					continue
 				}
-				object = append(object, &Field{Key: key.str, Value: value})
-				more = false
 			}
 		} else {
-			if del, ok := tok.(tokDelim); !ok || del.ch != ',' && del.ch != '}' {
-				return nil, fmt.Errorf("jtree: unexpected token at position %d: '%v'", tok.pos(), tok)
-			} else if del.ch == '}' {
-				break
-			} else {
-				more = true
+			tok = token{}
+		}
+		del, err := p.resync(err, tok, '}')
+		if err != nil {
+			if p.opt.recover {
+				return object, err
 			}
+			return nil, err
 		}
+		if del == '}' {
+			break
+		}
+		more = true
 	}
 	return object, nil
 }
 
-func (p *Parser) parse(tok token) (Node, error) {
-	switch t := tok.(type) {
+// resync records the syntax error in the error recovery mode and skips tokens up to the next separator or the end
+// of the current container. The offending token tok may be empty. Other errors and errors in the normal mode are returned as is
+func (p *Parser) resync(err error, tok token, closer rune) (rune, error) {
+	var serr *SyntaxError
+	if !p.opt.recover || !errors.As(err, &serr) {
+		return 0, err
+	}
+	p.errs = append(p.errs, serr)
+	depth := 0
+	for {
+		if tok.kind == tokDelim {
+			switch tok.ch {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth == 0 {
+					if tok.ch != closer {
+						// most likely belongs to the parent container
+						p.tok = tok
+					}
+					return closer, nil
+				}
+				depth--
+			case ',':
+				if depth == 0 {
+					return ',', nil
+				}
+			}
+		}
+		tok, err = p.next()
+		if err != nil {
+			if !errors.As(err, &serr) {
+				return 0, err
+			}
+			p.errs = append(p.errs, serr)
+			tok = token{}
+		}
+	}
+}
+
+func (p *Parser) parse(t token) (Node, error) {
+	p.nodes++
+	switch t.kind {
 	case tokString:
-		return String(t.str), nil
+		return p.opt.alloc.NewString(t.str), nil
 	case tokNum:
-		f, _, err := new(big.Float).Parse(t.str, 10)
-		if err != nil {
-			return nil, fmt.Errorf("jtree: %w", err)
+		if p.opt.strictNumbers && !validNumber(t.str) {
+			return nil, syntaxErrorf(t.p, "jtree: invalid number '%s' at position %d", t.str, t.p)
 		}
-		return (*Num)(f), nil
+		n := p.opt.alloc.NewNum()
+		if err := setNumber(n, t.str); err != nil {
+			return nil, syntaxErrorf(t.p, "jtree: %w", err)
+		}
+		return n, nil
 	case tokDelim:
+		if (t.ch == '{' || t.ch == '[') && p.opt.shallow > 0 && p.depth >= p.opt.shallow {
+			return p.parseRaw(t)
+		}
 		switch t.ch {
 		case '{':
 			return p.parseObject()
 		case '[':
 			return p.parseArray()
 		default:
-			return nil, fmt.Errorf("jtree: unexpected delimiter '%c' at position %d", t.ch, t.p)
+			return nil, syntaxErrorf(t.p, "jtree: unexpected delimiter '%c' at position %d", t.ch, t.p)
 		}
 	case tokRes:
 		switch t.str {
@@ -124,19 +562,269 @@ func (p *Parser) parse(tok token) (Node, error) {
 			return Bool(t.str == "true"), nil
 		case "null":
 			return Null{}, nil
+		case "NaN", "Infinity", "-Infinity":
+			if p.opt.nonFinite {
+				if t.str == "NaN" {
+					return NaN{}, nil
+				}
+				n := p.opt.alloc.NewNum()
+				(*big.Float)(n).SetInf(t.str[0] == '-')
+				return n, nil
+			}
+			fallthrough
 		default:
-			return nil, fmt.Errorf("jtree: undefined keyword '%s' at position %d", t.str, t.p)
+			return nil, syntaxErrorf(t.p, "jtree: undefined keyword '%s' at position %d", t.str, t.p)
 		}
 	default:
 		panic("unexpected token")
 	}
 }
 
-// Parse parses JSON stream into an AST representation
-func (p *Parser) Parse() (Node, error) {
-	tok, err := p.r.token()
+// skip consumes the value starting with tok without building the tree. The skipped text is validated the same way
+// Parse does, skipped values aren't counted as nodes
+func (p *Parser) skip(tok token) error {
+	switch {
+	case tok.delim('{'):
+		return p.members(func(key string, tok token) error { return p.skip(tok) })
+	case tok.delim('['):
+		return p.elements(p.skip)
+	}
+	return p.checkScalar(tok)
+}
+
+// checkScalar validates the scalar token reporting the same errors as parse without allocating the node
+func (p *Parser) checkScalar(t token) error {
+	switch t.kind {
+	case tokString:
+		return nil
+	case tokNum:
+		if p.opt.strictNumbers && !validNumber(t.str) {
+			return syntaxErrorf(t.p, "jtree: invalid number '%s' at position %d", t.str, t.p)
+		}
+		if isSmallInt(t.str) || validNumber(t.str) && !strings.ContainsAny(t.str, "eE") {
+			return nil
+		}
+		// let the generic parser decide on exponents and lenient literals
+		if _, _, err := p.num.Parse(t.str, 10); err != nil {
+			return syntaxErrorf(t.p, "jtree: %w", err)
+		}
+		return nil
+	case tokDelim:
+		return syntaxErrorf(t.p, "jtree: unexpected delimiter '%c' at position %d", t.ch, t.p)
+	case tokRes:
+		switch t.str {
+		case "true", "false", "null":
+			return nil
+		case "NaN", "Infinity", "-Infinity":
+			if p.opt.nonFinite {
+				return nil
+			}
+		}
+		return syntaxErrorf(t.p, "jtree: undefined keyword '%s' at position %d", t.str, t.p)
+	default:
+		panic("unexpected token")
+	}
+}
+
+// members iterates over object members calling fn with the key and the first token of the value.
+// fn must consume the value
+func (p *Parser) members(fn func(key string, tok token) error) error {
+	comma := false
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if tok.delim('}') && !(comma && p.opt.noTrailingCommas) {
+			return nil
+		}
+		key := tok
+		if key.kind != tokString {
+			if tok.kind == tokDelim {
+				return syntaxErrorf(tok.pos(), "jtree: unexpected delimiter '%c' at position %d", tok.ch, tok.pos())
+			}
+			return syntaxErrorf(tok.pos(), "jtree: object key expected at position %d: '%v'", tok.pos(), tok)
+		}
+		if err := p.checkKey(key); err != nil {
+			return err
+		}
+		if tok, err = p.next(); err != nil {
+			return err
+		}
+		if !tok.delim(':') {
+			return syntaxErrorf(tok.pos(), "jtree: colon expected at position %d: '%v'", tok.pos(), tok)
+		}
+		if tok, err = p.next(); err != nil {
+			return err
+		}
+		if err := fn(key.str, tok); err != nil {
+			return err
+		}
+		if comma, err = p.separator('}'); err != nil || !comma {
+			return err
+		}
+	}
+}
+
+// elements iterates over array elements calling fn with the first token of each element. fn must consume the element
+func (p *Parser) elements(fn func(tok token) error) error {
+	comma := false
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if tok.delim(']') && !(comma && p.opt.noTrailingCommas) {
+			return nil
+		}
+		if err := fn(tok); err != nil {
+			return err
+		}
+		if comma, err = p.separator(']'); err != nil || !comma {
+			return err
+		}
+	}
+}
+
+// separator consumes the comma or the closing delimiter returning true in the former case
+func (p *Parser) separator(closer rune) (bool, error) {
+	tok, err := p.next()
+	if err != nil {
+		return false, err
+	}
+	if tok.delim(',') || tok.delim(closer) {
+		return tok.ch == ',', nil
+	}
+	return false, syntaxErrorf(tok.pos(), "jtree: unexpected token at position %d: '%v'", tok.pos(), tok)
+}
+
+func (p *Parser) parseRaw(tok token) (Raw, error) {
+	start := p.r.capture(string(tok.ch))
+	err := p.skip(tok)
+	raw := p.r.captured(start)
 	if err != nil {
 		return nil, err
 	}
-	return p.parse(tok)
+	return Raw(raw), nil
+}
+
+// parseRawValue returns the source text of any value starting with tok
+func (p *Parser) parseRawValue(tok token) (Node, error) {
+	switch tok.kind {
+	case tokDelim:
+		if tok.ch == '{' || tok.ch == '[' {
+			return p.parseRaw(tok)
+		}
+	case tokString:
+		// the reader keeps the quoted text of the last string while raw capturing is enabled
+		return Raw(p.r.quoted), nil
+	}
+	// validate scalars
+	if _, err := p.parse(tok); err != nil {
+		return nil, err
+	}
+	return Raw(tok.String()), nil
+}
+
+func (p *Parser) next() (token, error) {
+	if p.tok.kind != tokNone {
+		tok := p.tok
+		p.tok = token{}
+		return tok, nil
+	}
+	if p.opt.progress != nil && p.r.nbytes >= p.nextReport {
+		if err := p.report(); err != nil {
+			return token{}, err
+		}
+	}
+	if p.cancel != nil {
+		if err := p.cancel.check(); err != nil {
+			return token{}, err
+		}
+	}
+	tok, err := p.r.token()
+	if p.opt.comments && len(p.r.comments) != 0 {
+		p.collectComments(tok)
+	}
+	return tok, err
+}
+
+func (p *Parser) report() error {
+	p.nextReport = p.r.nbytes + p.opt.progressInterval
+	return p.opt.progress(Progress{Bytes: p.r.nbytes, Nodes: p.nodes})
+}
+
+// Parse parses JSON stream into an AST representation. In the error recovery mode the best effort tree is returned
+// along with ErrorList containing all syntax errors found
+func (p *Parser) Parse() (Node, error) {
+	if p.opt.stats != nil {
+		defer p.reportStats(p.startStats())
+	}
+	n, err := p.parseTee()
+	if err == nil && p.opt.progress != nil {
+		err = p.report()
+	}
+	return n, err
+}
+
+func (p *Parser) parseTee() (Node, error) {
+	if p.opt.tee != nil {
+		start := p.r.capture("")
+		n, err := p.parseValue()
+		raw := p.r.captured(start)
+		if n != nil {
+			p.opt.tee(bytes.TrimLeft(raw, " \t\r\n"))
+		}
+		return n, err
+	}
+	return p.parseValue()
+}
+
+func (p *Parser) parseValue() (Node, error) {
+	p.errs = nil
+	p.comments = nil
+	tok, err := p.next()
+	if err == io.EOF {
+		return nil, err
+	}
+	var n Node
+	if err == nil {
+		if p.opt.comments {
+			p.leadingComments()
+		}
+		n, err = p.parse(tok)
+		if p.opt.comments {
+			p.endComments()
+		}
+	}
+	if !p.opt.recover {
+		return n, err
+	}
+	if err != nil {
+		var serr *SyntaxError
+		switch {
+		case err == io.EOF:
+			p.errs = append(p.errs, syntaxErrorf(p.r.off, "jtree: unexpected end of input at position %d", p.r.off))
+		case errors.As(err, &serr):
+			p.errs = append(p.errs, serr)
+		default:
+			return n, err
+		}
+	}
+	if len(p.errs) != 0 {
+		return n, p.errs
+	}
+	return n, nil
+}
+
+// ExpectEOF ensures that only whitespace follows the last parsed value
+func (p *Parser) ExpectEOF() error {
+	tok, err := p.next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return syntaxErrorf(tok.pos(), "jtree: unexpected data after top level value at position %d: '%v'", tok.pos(), tok)
 }
@@ -1,6 +1,9 @@
 package jtree_test
 
 import (
+	"context"
+	"errors"
+	"math"
 	"math/big"
 	"strings"
 	"testing"
@@ -77,3 +80,407 @@ func TestParseObject(t *testing.T) {
 		}
 	}
 }
+
+func TestParseKeyLimits(t *testing.T) {
+	src := []struct {
+		s   string
+		op  []jtree.ParserOption
+		err string
+	}{
+		{s: `{"abc":1}`, op: []jtree.ParserOption{jtree.OpMaxKeyLength(3)}},
+		{s: `{"abc":1,"abcd":2}`, op: []jtree.ParserOption{jtree.OpMaxKeyLength(3)}, err: "jtree: object key exceeds maximum length of 3 bytes at position 9"},
+		{s: `{"a\tb":1}`, op: []jtree.ParserOption{jtree.OpKeyValidator(jtree.NoControlChars)}, err: "jtree: invalid object key at position 1: control character U+0009"},
+		{s: `{"a b":1}`, op: []jtree.ParserOption{jtree.OpKeyValidator(jtree.NoControlChars)}},
+	}
+	for _, s := range src {
+		_, err := jtree.NewParser(strings.NewReader(s.s), s.op...).Parse()
+		if s.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, s.err)
+		}
+	}
+}
+
+func TestParseShallow(t *testing.T) {
+	src := `{"kind": "msg", "payload": {"a": [1, 2, {"b": "}"}], "c": null}, "list": [ [1] ,2]}`
+	node, err := jtree.NewParser(strings.NewReader(src), jtree.OpShallow(1)).Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, jtree.Object{
+		{"kind", jtree.String("msg")},
+		{"payload", jtree.Raw(`{"a": [1, 2, {"b": "}"}], "c": null}`)},
+		{"list", jtree.Raw(`[ [1] ,2]`)},
+	}, node)
+
+	var dest struct {
+		Kind    string        `json:"kind"`
+		Payload jtree.Node    `json:"payload"`
+		List    []interface{} `json:"list"`
+	}
+	if assert.NoError(t, node.Decode(&dest)) {
+		assert.Equal(t, jtree.Raw(`{"a": [1, 2, {"b": "}"}], "c": null}`), dest.Payload)
+		assert.Equal(t, []interface{}{[]interface{}{float64(1)}, float64(2)}, dest.List)
+	}
+
+	_, err = jtree.NewParser(strings.NewReader(`{"a": [1, 2}`), jtree.OpShallow(1)).Parse()
+	assert.EqualError(t, err, "jtree: unexpected token at position 11: '}'")
+	_, err = jtree.NewParser(strings.NewReader(`{"a": [1 2]}`), jtree.OpShallow(1)).Parse()
+	assert.EqualError(t, err, "jtree: unexpected token at position 9: '2'")
+	_, err = jtree.NewParser(strings.NewReader(`{"a": {"b" 1}}`), jtree.OpShallow(1)).Parse()
+	assert.EqualError(t, err, "jtree: colon expected at position 11: '1'")
+	_, err = jtree.NewParser(strings.NewReader(`{"a": [tru]}`), jtree.OpShallow(1)).Parse()
+	assert.EqualError(t, err, "jtree: undefined keyword 'tru' at position 7")
+}
+
+func TestParseRecover(t *testing.T) {
+	src := []struct {
+		s    string
+		n    jtree.Node
+		errs []string
+	}{
+		{
+			s: `{"a": 1, "b" 2, "c": [1, @, 3], "d": true}`,
+			n: jtree.Object{
+				{"a", newNumNode("1")},
+				{"c", jtree.Array{newNumNode("1"), newNumNode("3")}},
+				{"d", jtree.Bool(true)},
+			},
+			errs: []string{
+				"jtree: colon expected at position 13: '2'",
+				"jtree: unexpected character '@' at position 25",
+			},
+		},
+		{
+			s: `{"a": [1, 2 3], "b": nope}`,
+			n: jtree.Object{
+				{"a", jtree.Array{newNumNode("1"), newNumNode("2")}},
+			},
+			errs: []string{
+				"jtree: unexpected token at position 12: '3'",
+				"jtree: undefined keyword 'nope' at position 21",
+			},
+		},
+		{
+			s: `[{"a": 1], 2]`,
+			n: jtree.Array{jtree.Object{{"a", newNumNode("1")}}},
+			errs: []string{
+				"jtree: unexpected token at position 8: ']'",
+			},
+		},
+		{
+			s: `[1, [2, 3`,
+			n: jtree.Array{newNumNode("1"), jtree.Array{newNumNode("2"), newNumNode("3")}},
+			errs: []string{
+				"jtree: unexpected end of input at position 9",
+			},
+		},
+		{
+			s: `[1, 2]`,
+			n: jtree.Array{newNumNode("1"), newNumNode("2")},
+		},
+	}
+	for _, s := range src {
+		node, err := jtree.NewParser(strings.NewReader(s.s), jtree.OpRecover).Parse()
+		assert.Equal(t, s.n, node, s.s)
+		if s.errs == nil {
+			assert.NoError(t, err)
+			continue
+		}
+		var list jtree.ErrorList
+		if assert.ErrorAs(t, err, &list, s.s) {
+			msgs := make([]string, len(list))
+			for i, e := range list {
+				msgs[i] = e.Error()
+			}
+			assert.Equal(t, s.errs, msgs, s.s)
+		}
+	}
+}
+
+func TestParseSkip(t *testing.T) {
+	src := `{"id": 1, "blob": {"x": [1, 2, 3]}, "items": [{"id": 2, "data": "xxx"}, {"id": 3, "data": [true]}]}`
+	node, err := jtree.NewParser(strings.NewReader(src), jtree.OpSkipPaths("/blob", "/items/*/data")).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Object{
+			{"id", newNumNode("1")},
+			{"items", jtree.Array{
+				jtree.Object{{"id", newNumNode("2")}},
+				jtree.Object{{"id", newNumNode("3")}},
+			}},
+		}, node)
+	}
+
+	node, err = jtree.NewParser(strings.NewReader(`[1, 2, 3, 4]`), jtree.OpSkipFunc(func(path []string) bool {
+		return path[0] == "1" || path[0] == "3"
+	})).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Array{newNumNode("1"), newNumNode("3")}, node)
+	}
+
+	// skipped values are validated without allocating or counting nodes
+	var (
+		alloc = countingAllocator{interned: make(map[string]jtree.String)}
+		stats jtree.ParseStats
+	)
+	_, err = jtree.NewParser(strings.NewReader(src), jtree.OpSkipPaths("/blob", "/items/*/data"), jtree.OpAllocator(&alloc),
+		jtree.OpParseStats(func(s jtree.ParseStats) { stats = s })).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, alloc.strings)
+		assert.Equal(t, 3, alloc.nums)
+		assert.Equal(t, int64(7), stats.Nodes)
+	}
+	for _, src := range []string{`{"blob": [1.2.3]}`, `{"blob": {"a": tru}}`, `{"blob": [1e]}`, `{"blob": [NaN]}`} {
+		_, expect := jtree.NewParser(strings.NewReader(src)).Parse()
+		_, err := jtree.NewParser(strings.NewReader(src), jtree.OpSkipPaths("/blob")).Parse()
+		if assert.Error(t, expect, src) {
+			assert.EqualError(t, err, expect.Error(), src)
+		}
+	}
+}
+
+func TestParseTeeShallow(t *testing.T) {
+	var raw []byte
+	node, err := jtree.NewParser(strings.NewReader(` {"a": {"b": [1]}} `), jtree.OpShallow(1), jtree.OpTee(func(b []byte) { raw = b })).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Object{{"a", jtree.Raw(`{"b": [1]}`)}}, node)
+		assert.Equal(t, `{"a": {"b": [1]}}`, string(raw))
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	src := []struct {
+		s   string
+		err string
+	}{
+		{s: `[1, -0.5e+10, 0, "A\"\\\/\b\f\n\r\t"]`},
+		{s: `{"a": [true, false, null], "b": {}}`},
+		{s: `[1,]`, err: "jtree: unexpected delimiter ']' at position 3"},
+		{s: `{"a":1,}`, err: "jtree: unexpected delimiter '}' at position 7"},
+		{s: `[.5]`, err: "jtree: invalid number '.5' at position 1"},
+		{s: `[01]`, err: "jtree: invalid number '01' at position 1"},
+		{s: `[1.]`, err: "jtree: invalid number '1.' at position 1"},
+		{s: `[-01.5]`, err: "jtree: invalid number '-01.5' at position 1"},
+		{s: `["\x41"]`, err: "jtree: invalid escape sequence '\\x' at position 3"},
+		{s: `["\z"]`, err: "jtree: invalid escape sequence '\\z' at position 3"},
+		{s: "[\"a\tb\"]", err: "jtree: unescaped control character U+0009 at position 3"},
+	}
+	for _, s := range src {
+		_, err := jtree.NewParser(strings.NewReader(s.s), jtree.OpStrict).Parse()
+		if s.err == "" {
+			assert.NoError(t, err, s.s)
+		} else {
+			assert.EqualError(t, err, s.err, s.s)
+		}
+		// lenient by default
+		_, err = jtree.NewParser(strings.NewReader(s.s)).Parse()
+		assert.NoError(t, err, s.s)
+	}
+
+	assert.Equal(t, jtree.ParserBehavior{}, jtree.Behavior(jtree.OpStrict))
+	assert.Equal(t, jtree.ParserBehavior{TrailingCommas: true, LooseEscapes: true, LooseNumbers: true, ControlChars: true}, jtree.Behavior())
+	assert.Equal(t, jtree.ParserBehavior{LooseEscapes: true, LooseNumbers: true, ControlChars: true}, jtree.Behavior(jtree.OpNoTrailingCommas))
+}
+
+func TestParseNonFinite(t *testing.T) {
+	src := `[NaN, Infinity, -Infinity, -1]`
+	node, err := jtree.NewParser(strings.NewReader(src), jtree.OpAllowNonFinite).Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, jtree.Array{
+		jtree.NaN{},
+		(*jtree.Num)(new(big.Float).SetInf(false)),
+		(*jtree.Num)(new(big.Float).SetInf(true)),
+		newNumNode("-1"),
+	}, node)
+
+	var dest []float64
+	if assert.NoError(t, node.Decode(&dest)) {
+		assert.True(t, math.IsNaN(dest[0]))
+		assert.Equal(t, []float64{math.Inf(1), math.Inf(-1), -1}, dest[1:])
+	}
+
+	_, err = jtree.NewParser(strings.NewReader(src)).Parse()
+	assert.EqualError(t, err, "jtree: unexpected character 'N' at position 1")
+}
+
+func TestParseSingleQuotes(t *testing.T) {
+	src := `{'a': 'it\'s', "b": '"q"\n'}`
+	node, err := jtree.NewParser(strings.NewReader(src), jtree.OpAllowSingleQuotes, jtree.OpStrict).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Object{
+			{"a", jtree.String("it's")},
+			{"b", jtree.String("\"q\"\n")},
+		}, node)
+	}
+	_, err = jtree.NewParser(strings.NewReader(src)).Parse()
+	assert.EqualError(t, err, "jtree: unexpected character ''' at position 1")
+}
+
+func TestParseProgress(t *testing.T) {
+	src := `["привет", 1, 2, 3, {"a": [4, 5, 6]}]`
+	var reports []jtree.Progress
+	fn := func(p jtree.Progress) error {
+		reports = append(reports, p)
+		return nil
+	}
+	_, err := jtree.NewParser(strings.NewReader(src), jtree.OpProgress(16, fn)).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []jtree.Progress{
+			{Bytes: 16, Nodes: 2},
+			{Bytes: 33, Nodes: 7},
+			{Bytes: 43, Nodes: 10},
+		}, reports)
+	}
+
+	errBudget := errors.New("budget exceeded")
+	_, err = jtree.NewParser(strings.NewReader(src), jtree.OpProgress(16, func(p jtree.Progress) error { return errBudget })).Parse()
+	assert.Equal(t, errBudget, err)
+}
+
+func TestParseRawPaths(t *testing.T) {
+	src := `{"doc": {"b": 1.50, "a": "x"}, "sig": "Ab", "n": 1.50, "list": [ true ,[1] ], "x": 1.50}`
+	node, err := jtree.NewParser(strings.NewReader(src), jtree.OpRawPaths("/doc", "/sig", "/n", "/list/0", "/list/1")).Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, jtree.Object{
+		{"doc", jtree.Raw(`{"b": 1.50, "a": "x"}`)},
+		{"sig", jtree.Raw(`"Ab"`)},
+		{"n", jtree.Raw(`1.50`)},
+		{"list", jtree.Array{jtree.Raw(`true`), jtree.Raw(`[1]`)}},
+		{"x", newNumNode("1.5")},
+	}, node)
+
+	var dest struct {
+		Doc  jtree.Raw   `json:"doc"`
+		Sig  jtree.Raw   `json:"sig"`
+		N    float64     `json:"n"`
+		List []jtree.Raw `json:"list"`
+		X    jtree.Raw   `json:"x"`
+	}
+	if assert.NoError(t, node.Decode(&dest)) {
+		assert.Equal(t, jtree.Raw(`{"b": 1.50, "a": "x"}`), dest.Doc)
+		assert.Equal(t, jtree.Raw(`"Ab"`), dest.Sig)
+		assert.Equal(t, 1.5, dest.N)
+		assert.Equal(t, []jtree.Raw{jtree.Raw(`true`), jtree.Raw(`[1]`)}, dest.List)
+		assert.Equal(t, jtree.Raw(`1.5`), dest.X)
+	}
+
+	_, err = jtree.NewParser(strings.NewReader(`{"a": tru}`), jtree.OpRawPaths("/a")).Parse()
+	assert.EqualError(t, err, "jtree: undefined keyword 'tru' at position 6")
+}
+
+func TestDecodeRaw(t *testing.T) {
+	var dest struct {
+		A jtree.Raw `json:"a"`
+		B jtree.Raw `json:"b"`
+	}
+	src := mustParse(t, `{"a": {"x": ["q\"\n\u0001", 1e3, null, false]}, "b": null}`)
+	if assert.NoError(t, src.Decode(&dest)) {
+		assert.Equal(t, jtree.Raw(`{"x":["q\"\n\u0001",1000,null,false]}`), dest.A)
+		assert.Equal(t, jtree.Raw(`null`), dest.B)
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	src := `// header
+{
+	/* leading */ "a": 1, // trailing a
+	"b": [
+		1 /* trailing 0 */,
+		/* leading 1 */ 2
+		// dangling b
+	],
+	"c": {"d": null} // trailing c
+} /* trailing root */`
+	p := jtree.NewParser(strings.NewReader(src), jtree.OpAllowComments)
+	node, err := p.Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, p.ExpectEOF())
+	assert.True(t, jtree.Equal(mustParse(t, `{"a": 1, "b": [1, 2], "c": {"d": null}}`), node))
+
+	type comment struct {
+		path     string
+		text     string
+		trailing bool
+	}
+	var comments []comment
+	for _, c := range p.Comments() {
+		comments = append(comments, comment{strings.Join(c.Path, "/"), c.Text, c.Trailing})
+	}
+	assert.Equal(t, []comment{
+		{"", "// header", false},
+		{"a", "/* leading */", false},
+		{"a", "// trailing a", true},
+		{"b/0", "/* trailing 0 */", true},
+		{"b/1", "/* leading 1 */", false},
+		{"b", "// dangling b", true},
+		{"c", "// trailing c", true},
+		{"", "/* trailing root */", true},
+	}, comments)
+	assert.Equal(t, int64(0), p.Comments()[0].Offset)
+
+	for _, tt := range []struct {
+		src string
+		err string
+	}{
+		{`[1, /* 2 ]`, "jtree: unterminated comment at position 4"},
+		{`[1, / 2]`, "jtree: unexpected character '/' at position 4"},
+	} {
+		_, err := jtree.NewParser(strings.NewReader(tt.src), jtree.OpAllowComments).Parse()
+		assert.EqualError(t, err, tt.err)
+	}
+	_, err = jtree.NewParser(strings.NewReader(`[1, // x` + "\n" + `2]`)).Parse()
+	assert.EqualError(t, err, "jtree: unexpected character '/' at position 4")
+}
+
+func TestParseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := jtree.NewParser(strings.NewReader(`[1, 2, 3]`))
+	_, err := p.ParseContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	p = jtree.NewParser(strings.NewReader(`[1, 2, 3]`))
+	n, err := p.ParseContext(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "array", n.Type())
+	}
+}
+
+func TestParseIntegerFastPath(t *testing.T) {
+	for _, src := range []string{"0", "-0", "1", "-1", "123456789012345678", "-123456789012345678", "1234567890123456789", "1.5", "1e3"} {
+		n, err := jtree.NewParser(strings.NewReader(src)).Parse()
+		if !assert.NoError(t, err) {
+			continue
+		}
+		expect, _, _ := new(big.Float).Parse(src, 10)
+		got := (*big.Float)(n.(*jtree.Num))
+		assert.Equal(t, 0, expect.Cmp(got), src)
+		assert.Equal(t, expect.Prec(), got.Prec(), src)
+		assert.Equal(t, expect.Signbit(), got.Signbit(), src)
+	}
+}
+
+func TestParseSurrogates(t *testing.T) {
+	tests := map[string]string{
+		`"\uD834\uDD1E"`:       "\U0001D11E",
+		`"\uD834"`:             "\uFFFD",
+		`"\uD834x"`:            "\uFFFDx",
+		`"\uDD1E"`:             "\uFFFD",
+		`"\uD834\uD834\uDD1E"`: "\uFFFD\U0001D11E",
+		`"\uD834\n"`:           "\uFFFD\n",
+		`"a\u00e9b"`:           "a\u00e9b",
+	}
+	for src, expect := range tests {
+		n, err := jtree.NewParser(strings.NewReader(src)).Parse()
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, jtree.String(expect), n, src)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package jtree
+
+import (
+	"reflect"
+	"strings"
+)
+
+// MergePatch applies the JSON Merge Patch (RFC 7386) to target and returns the result. Arguments are not modified
+func MergePatch(target, patch Node) Node {
+	p, ok := patch.(Object)
+	if !ok {
+		return patch
+	}
+	t, _ := target.(Object)
+	out := NewObjectIndex(t)
+	for _, f := range p {
+		if _, ok := f.Value.(Null); ok {
+			out.Delete(f.Key)
+			continue
+		}
+		v, _ := out.Get(f.Key)
+		out.Set(f.Key, MergePatch(v, f.Value))
+	}
+	return out.Object()
+}
+
+// PatchStrategy maps list paths to merge keys used by StrategicMergePatch. The path consists of slash separated
+// object keys leading to the list, array levels are not included, i.e. "/spec/containers/ports". The empty merge key
+// makes the list of scalars to be merged as a set. Lists without a strategy are replaced
+type PatchStrategy map[string]string
+
+// StrategyFromType builds PatchStrategy from `patchStrategy:"merge"` and `patchMergeKey:"name"` struct tags
+// of slice fields of t
+func StrategyFromType(t reflect.Type) PatchStrategy {
+	s := make(PatchStrategy)
+	strategyFromType(t, "", s, make(map[reflect.Type]bool))
+	return s
+}
+
+func strategyFromType(t reflect.Type, path string, s PatchStrategy, visited map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+	for _, f := range VisibleFields(t) {
+		p := path + "/" + f.Name
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 {
+			ft = ft.Elem()
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if key := f.Tag.Get("patchMergeKey"); key != "" {
+				s[p] = key
+			} else if strings.Contains(f.Tag.Get("patchStrategy"), "merge") && ft.Kind() != reflect.Struct {
+				s[p] = ""
+			}
+		}
+		strategyFromType(ft, p, s, visited)
+	}
+}
+
+const patchDirective = "$patch"
+
+func patchDirectiveOf(n Node) string {
+	if o, ok := n.(Object); ok {
+		if d, ok := o.FieldByName(patchDirective).(String); ok {
+			return string(d)
+		}
+	}
+	return ""
+}
+
+// withoutDirectives returns the copy of n without "$patch" keys
+func withoutDirectives(n Node) Node {
+	switch node := n.(type) {
+	case Object:
+		out := make(Object, 0, len(node))
+		for _, f := range node {
+			if f.Key == patchDirective {
+				continue
+			}
+			if _, ok := f.Value.(Null); ok {
+				continue
+			}
+			out = append(out, &Field{Key: f.Key, Value: withoutDirectives(f.Value)})
+		}
+		return out
+	case Array:
+		out := make(Array, 0, len(node))
+		for _, elem := range node {
+			if patchDirectiveOf(elem) == "" {
+				out = append(out, withoutDirectives(elem))
+			}
+		}
+		return out
+	default:
+		return n
+	}
+}
+
+// StrategicMergePatch applies Kubernetes style strategic merge patch to target and returns the result. Objects are
+// merged recursively and null values delete keys like in RFC 7386. Lists listed in strategy are merged by the merge key:
+// patch elements update target elements with the same key value or are appended otherwise. {"$patch": "delete"}
+// element removes the matching target element, {"$patch": "replace"} element or object member replaces the
+// whole list or object. Arguments are not modified
+func StrategicMergePatch(target, patch Node, strategy PatchStrategy) Node {
+	return strategicMerge(target, patch, "", strategy)
+}
+
+func strategicMerge(target, patch Node, path string, strategy PatchStrategy) Node {
+	p, ok := patch.(Object)
+	if !ok {
+		return withoutDirectives(patch)
+	}
+	if patchDirectiveOf(p) == "replace" {
+		return withoutDirectives(p)
+	}
+	t, _ := target.(Object)
+	out := NewObjectIndex(t)
+	for _, f := range p {
+		if f.Key == patchDirective {
+			continue
+		}
+		if _, ok := f.Value.(Null); ok {
+			out.Delete(f.Key)
+			continue
+		}
+		tv, _ := out.Get(f.Key)
+		p := path + "/" + f.Key
+		var v Node
+		if key, ok := strategy[p]; ok {
+			if pa, ok := f.Value.(Array); ok {
+				ta, _ := tv.(Array)
+				v = mergeList(ta, pa, key, p, strategy)
+			}
+		}
+		if v == nil {
+			v = strategicMerge(tv, f.Value, p, strategy)
+		}
+		out.Set(f.Key, v)
+	}
+	return out.Object()
+}
+
+func mergeList(target, patch Array, key, path string, strategy PatchStrategy) Array {
+	for _, elem := range patch {
+		if patchDirectiveOf(elem) == "replace" {
+			return withoutDirectives(patch).(Array)
+		}
+	}
+	out := make(Array, len(target), len(target)+len(patch))
+	copy(out, target)
+	find := func(n Node) int {
+		var kv Node = n
+		if key != "" {
+			o, ok := n.(Object)
+			if !ok {
+				return -1
+			}
+			if kv = o.FieldByName(key); kv == nil {
+				return -1
+			}
+		}
+		for i, elem := range out {
+			ev := elem
+			if key != "" {
+				o, ok := elem.(Object)
+				if !ok {
+					continue
+				}
+				ev = o.FieldByName(key)
+			}
+			if ev != nil && scalarEqual(kv, ev) {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, elem := range patch {
+		i := find(elem)
+		switch {
+		case patchDirectiveOf(elem) == "delete":
+			if i >= 0 {
+				out = append(out[:i:i], out[i+1:]...)
+			}
+		case i >= 0:
+			out[i] = strategicMerge(out[i], elem, path, strategy)
+		default:
+			out = append(out, withoutDirectives(elem))
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package jtree_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParse(t *testing.T, s string) jtree.Node {
+	n, err := jtree.NewParser(strings.NewReader(s)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestMergePatch(t *testing.T) {
+	// RFC 7386 appendix A
+	tst := []struct {
+		target, patch, expect string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tst {
+		target := mustParse(t, tt.target)
+		assert.Equal(t, mustParse(t, tt.expect), jtree.MergePatch(target, mustParse(t, tt.patch)), tt.patch)
+		assert.Equal(t, mustParse(t, tt.target), target)
+	}
+}
+
+type podSpec struct {
+	Containers []container `json:"containers" patchStrategy:"merge" patchMergeKey:"name"`
+	Finalizers []string    `json:"finalizers" patchStrategy:"merge"`
+	Args       []string    `json:"args"`
+}
+
+type container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	Ports []port `json:"ports" patchStrategy:"merge" patchMergeKey:"containerPort"`
+}
+
+type port struct {
+	ContainerPort int `json:"containerPort"`
+}
+
+func TestStrategicMergePatch(t *testing.T) {
+	strategy := jtree.StrategyFromType(reflect.TypeOf(struct {
+		Spec podSpec `json:"spec"`
+	}{}))
+	assert.Equal(t, jtree.PatchStrategy{
+		"/spec/containers":       "name",
+		"/spec/containers/ports": "containerPort",
+		"/spec/finalizers":       "",
+	}, strategy)
+
+	target := mustParse(t, `{"spec": {
+		"containers": [
+			{"name": "app", "image": "app:1", "ports": [{"containerPort": 80}]},
+			{"name": "sidecar", "image": "sidecar:1"},
+			{"name": "old", "image": "old:1"}
+		],
+		"finalizers": ["a", "b"],
+		"args": ["-x"]
+	}}`)
+	patch := mustParse(t, `{"spec": {
+		"containers": [
+			{"name": "app", "image": "app:2", "ports": [{"containerPort": 443}]},
+			{"name": "old", "$patch": "delete"},
+			{"name": "new", "image": "new:1"}
+		],
+		"finalizers": ["b", "c"],
+		"args": ["-y"]
+	}}`)
+	expect := mustParse(t, `{"spec": {
+		"containers": [
+			{"name": "app", "image": "app:2", "ports": [{"containerPort": 80}, {"containerPort": 443}]},
+			{"name": "sidecar", "image": "sidecar:1"},
+			{"name": "new", "image": "new:1"}
+		],
+		"finalizers": ["a", "b", "c"],
+		"args": ["-y"]
+	}}`)
+	assert.Equal(t, expect, jtree.StrategicMergePatch(target, patch, strategy))
+
+	patch = mustParse(t, `{"spec": {"containers": [{"$patch": "replace"}, {"name": "only"}]}}`)
+	assert.Equal(t, mustParse(t, `[{"name": "only"}]`),
+		jtree.StrategicMergePatch(target, patch, strategy).(jtree.Object)[0].Value.(jtree.Object)[0].Value)
+}
@@ -0,0 +1,99 @@
+package jtree
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// fieldPlan is the struct field with its tag options parsed once per type
+type fieldPlan struct {
+	*StructField
+	tags       []fieldTag
+	decoder    string
+	encodedBy  string
+	union      string
+	isInline   bool
+	isRequired bool
+	isOptional bool // excluded from the set of fields required by OpRequireFields
+}
+
+func (f *fieldPlan) required(all bool) bool {
+	return f.isRequired || all && !f.isOptional
+}
+
+// structPlan is the cached decoding plan of the struct type
+type structPlan struct {
+	fields map[string]*fieldPlan
+	list   []*fieldPlan
+	inline *fieldPlan
+	direct bool  // all fields can be decoded without the sibling members
+	err    error // invalid field tags
+}
+
+var structPlans sync.Map // map[reflect.Type]*structPlan
+
+func planFor(t reflect.Type) *structPlan {
+	if p, ok := structPlans.Load(t); ok {
+		return p.(*structPlan)
+	}
+	fields := make(map[string]*StructField)
+	list := collectFields(t, nil, nil, fields)
+	p := &structPlan{
+		fields: make(map[string]*fieldPlan, len(fields)),
+		list:   make([]*fieldPlan, len(list)),
+		direct: true,
+	}
+	for i, f := range list {
+		tags, err := parseFieldTags(f.Options)
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("jtree: field %s of %v: %w", f.StructField.Name, t, err)
+			p.direct = false
+		}
+		fp := &fieldPlan{
+			StructField: f,
+			tags:        tags,
+			isInline:    f.inline(),
+			isRequired:  f.hasOption("required"),
+		}
+		fp.decoder, _ = f.option("decoder")
+		fp.encodedBy, _ = f.option("encodedby")
+		fp.union, _ = f.option("union")
+		fp.isOptional = fp.isInline || f.hasOption("omitempty") || reflect.PtrTo(f.Type).Implements(optionalDecoderType)
+		if fp.isInline {
+			p.inline = fp
+		}
+		if fp.isInline || fp.decoder != "" || fp.encodedBy != "" || fp.union != "" {
+			p.direct = false
+		}
+		if fields[f.Name] == f {
+			p.fields[f.Name] = fp
+		}
+		p.list[i] = fp
+	}
+	v, _ := structPlans.LoadOrStore(t, p)
+	return v.(*structPlan)
+}
+
+// encodingCache holds the encoding resolved by name for the last used registry
+type encodingCache struct {
+	name     string
+	resolved atomic.Pointer[resolvedEncoding]
+}
+
+type resolvedEncoding struct {
+	reg *EncodingRegistry
+	gen uint64
+	enc Encoding
+}
+
+func (c *encodingCache) get(reg *EncodingRegistry) Encoding {
+	gen := encodingsGen.Load()
+	if r := c.resolved.Load(); r != nil && r.reg == reg && r.gen == gen {
+		return r.enc
+	}
+	enc := reg.get(c.name)
+	c.resolved.Store(&resolvedEncoding{reg: reg, gen: gen, enc: enc})
+	return enc
+}
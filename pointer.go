@@ -0,0 +1,118 @@
+package jtree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer splits the JSON Pointer (RFC 6901) into unescaped reference tokens
+func ParsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return []string{}, nil
+	}
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("jtree: invalid JSON pointer: %q", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		if strings.Contains(t, "~") {
+			for j := 0; j < len(t); j++ {
+				if t[j] == '~' && (j == len(t)-1 || t[j+1] != '0' && t[j+1] != '1') {
+					return nil, fmt.Errorf("jtree: invalid escape sequence in JSON pointer: %q", ptr)
+				}
+			}
+			tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+		}
+	}
+	return tokens, nil
+}
+
+func pointerIndex(tok string, n int) (int, bool) {
+	if tok == "" || len(tok) > 1 && tok[0] == '0' {
+		return 0, false
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+// Pointer returns the node referenced by the JSON Pointer (RFC 6901) like "/a/b/0"
+func Pointer(n Node, ptr string) (Node, error) {
+	tokens, err := ParsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	for _, tok := range tokens {
+		switch node := n.(type) {
+		case Object:
+			if n = node.FieldByName(tok); n == nil {
+				return nil, fmt.Errorf("jtree: key %q not found: %s", tok, ptr)
+			}
+		case Array:
+			i, ok := pointerIndex(tok, len(node))
+			if !ok {
+				return nil, fmt.Errorf("jtree: invalid array index %q: %s", tok, ptr)
+			}
+			n = node[i]
+		default:
+			return nil, fmt.Errorf("jtree: %s node can't be indexed: %s", n.Type(), ptr)
+		}
+	}
+	return n, nil
+}
+
+// SetPointer replaces the node referenced by the JSON Pointer with value. A missing object key is added to the object.
+// "-" as the last reference token appends value to the array. Containers are modified in place where possible,
+// the resulting root node is returned
+func SetPointer(root Node, ptr string, value Node) (Node, error) {
+	tokens, err := ParsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	return setPointer(root, tokens, value, ptr)
+}
+
+func setPointer(n Node, tokens []string, value Node, ptr string) (Node, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	tok := tokens[0]
+	switch node := n.(type) {
+	case Object:
+		for _, f := range node {
+			if f.Key == tok {
+				v, err := setPointer(f.Value, tokens[1:], value, ptr)
+				if err != nil {
+					return nil, err
+				}
+				f.Value = v
+				return node, nil
+			}
+		}
+		if len(tokens) != 1 {
+			return nil, fmt.Errorf("jtree: key %q not found: %s", tok, ptr)
+		}
+		return append(node, &Field{Key: tok, Value: value}), nil
+
+	case Array:
+		if tok == "-" && len(tokens) == 1 {
+			return append(node, value), nil
+		}
+		i, ok := pointerIndex(tok, len(node))
+		if !ok {
+			return nil, fmt.Errorf("jtree: invalid array index %q: %s", tok, ptr)
+		}
+		v, err := setPointer(node[i], tokens[1:], value, ptr)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = v
+		return node, nil
+
+	default:
+		return nil, fmt.Errorf("jtree: %s node can't be indexed: %s", n.Type(), ptr)
+	}
+}
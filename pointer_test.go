@@ -0,0 +1,68 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPointer(t *testing.T) {
+	doc := jtree.Object{
+		{"foo", jtree.Array{jtree.String("bar"), jtree.String("baz")}},
+		{"", newNumNode("0")},
+		{"a/b", newNumNode("1")},
+		{"m~n", newNumNode("8")},
+	}
+	tst := []struct {
+		ptr    string
+		expect jtree.Node
+		err    string
+	}{
+		{ptr: "", expect: doc},
+		{ptr: "/foo", expect: doc[0].Value},
+		{ptr: "/foo/0", expect: jtree.String("bar")},
+		{ptr: "/", expect: newNumNode("0")},
+		{ptr: "/a~1b", expect: newNumNode("1")},
+		{ptr: "/m~0n", expect: newNumNode("8")},
+		{ptr: "/foo/2", err: `jtree: invalid array index "2": /foo/2`},
+		{ptr: "/foo/01", err: `jtree: invalid array index "01": /foo/01`},
+		{ptr: "/bar", err: `jtree: key "bar" not found: /bar`},
+		{ptr: "/foo/0/x", err: `jtree: string node can't be indexed: /foo/0/x`},
+		{ptr: "foo", err: `jtree: invalid JSON pointer: "foo"`},
+		{ptr: "/m~2n", err: `jtree: invalid escape sequence in JSON pointer: "/m~2n"`},
+	}
+	for _, tt := range tst {
+		n, err := jtree.Pointer(doc, tt.ptr)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.ptr)
+		} else if assert.NoError(t, err, tt.ptr) {
+			assert.Equal(t, tt.expect, n, tt.ptr)
+		}
+	}
+}
+
+func TestSetPointer(t *testing.T) {
+	var doc jtree.Node = jtree.Object{
+		{"foo", jtree.Array{jtree.String("bar")}},
+	}
+	var err error
+	doc, err = jtree.SetPointer(doc, "/foo/0", jtree.String("baz"))
+	assert.NoError(t, err)
+	doc, err = jtree.SetPointer(doc, "/foo/-", jtree.String("qux"))
+	assert.NoError(t, err)
+	doc, err = jtree.SetPointer(doc, "/new", jtree.Bool(true))
+	assert.NoError(t, err)
+	_, err = jtree.SetPointer(doc, "/x/y", jtree.Bool(true))
+	assert.EqualError(t, err, `jtree: key "x" not found: /x/y`)
+
+	assert.Equal(t, jtree.Object{
+		{"foo", jtree.Array{jtree.String("baz"), jtree.String("qux")}},
+		{"new", jtree.Bool(true)},
+	}, doc)
+
+	doc, err = jtree.SetPointer(doc, "", jtree.Null{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Null{}, doc)
+	}
+}
@@ -0,0 +1,87 @@
+package jtree
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpProtoJSON enables protobuf JSON mapping conventions: time.Duration values are decoded from strings like "3.5s",
+// 64-bit integers and floating point numbers are accepted as strings, floating point numbers also accept "NaN",
+// "Infinity" and "-Infinity", and byte slices accept both standard and URL-safe base64 with or without padding.
+// Timestamps and wrapper types are handled by time.Time and pointer destinations respectively.
+// The option is global for all Decode calls in chain
+func OpProtoJSON(o *options) { o.mutableCtx().proto = true }
+
+// protoAdapter handles protobuf JSON conventions. It returns true if the value has been decoded
+func protoAdapter(node Node, out reflect.Value, opt *options) (bool, error) {
+	s, ok := node.(String)
+	if !ok {
+		return false, nil
+	}
+	t := out.Type()
+	k := t.Kind()
+	switch {
+	case t == durationType:
+		d, err := parseProtoDuration(string(s))
+		if err != nil {
+			return true, err
+		}
+		out.SetInt(int64(d))
+		return true, nil
+
+	case k == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && opt.enc == nil && !opt.str:
+		enc := base64.RawStdEncoding
+		if strings.ContainsAny(string(s), "-_") {
+			enc = base64.RawURLEncoding
+		}
+		buf, err := enc.DecodeString(strings.TrimRight(string(s), "="))
+		if err != nil {
+			return true, fmt.Errorf("jtree: %w", err)
+		}
+		out.Set(reflect.ValueOf(buf).Convert(t))
+		return true, nil
+
+	case k >= reflect.Int && k <= reflect.Uintptr || k == reflect.Float32 || k == reflect.Float64:
+		// decode numeric strings
+		opt.str = true
+	}
+	return false, nil
+}
+
+// parseProtoDuration parses durations in the form of seconds with optional fractional part followed by "s"
+func parseProtoDuration(s string) (time.Duration, error) {
+	if !strings.HasSuffix(s, "s") {
+		return 0, fmt.Errorf("jtree: invalid duration: %s", s)
+	}
+	v := s[:len(s)-1]
+	neg := strings.HasPrefix(v, "-")
+	if neg {
+		v = v[1:]
+	}
+	sec, frac := v, ""
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		sec, frac = v[:i], v[i+1:]
+	}
+	if sec == "" || len(frac) > 9 {
+		return 0, fmt.Errorf("jtree: invalid duration: %s", s)
+	}
+	secs, err := strconv.ParseUint(sec, 10, 64)
+	if err != nil || secs > uint64(1<<63-1)/uint64(time.Second) {
+		return 0, fmt.Errorf("jtree: invalid duration: %s", s)
+	}
+	var nanos uint64
+	if frac != "" {
+		if nanos, err = strconv.ParseUint(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil {
+			return 0, fmt.Errorf("jtree: invalid duration: %s", s)
+		}
+	}
+	d := time.Duration(secs)*time.Second + time.Duration(nanos)
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
@@ -1,40 +1,41 @@
 package jtree
 
 import (
-	"fmt"
 	"io"
-	"strings"
 	"unicode/utf16"
+	"unicode/utf8"
 )
 
-type token interface {
-	pos() int64
-	String() string
-}
-
-type tokDelim struct {
-	ch rune
-	p  int64
-}
+// tokenKind is the kind of the internal lexical token
+type tokenKind uint8
 
-func (t tokDelim) pos() int64     { return t.p }
-func (t tokDelim) String() string { return string(rune(t.ch)) }
+const (
+	tokNone tokenKind = iota
+	tokDelim
+	tokString
+	tokNum
+	tokRes // reserved word
+)
 
-type tokString struct {
-	str string
-	p   int64
+// token is passed by value so the lexer doesn't allocate per token
+type token struct {
+	kind tokenKind
+	ch   rune // delimiter character
+	str  string
+	p    int64
 }
 
-func (t tokString) pos() int64     { return t.p }
-func (t tokString) String() string { return t.str }
+func (t token) pos() int64 { return t.p }
 
-type tokNum struct {
-	tokString
+func (t token) String() string {
+	if t.kind == tokDelim {
+		return string(t.ch)
+	}
+	return t.str
 }
 
-type tokRes struct {
-	tokString
-}
+// delim returns true if the token is the delimiter ch
+func (t token) delim(ch rune) bool { return t.kind == tokDelim && t.ch == ch }
 
 func isSpace(c rune) bool {
 	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
@@ -44,112 +45,346 @@ func isNum(c rune) bool {
 	return c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.' || c == 'e' || c == 'E'
 }
 
+func isEscape(c rune) bool {
+	return c == '"' || c == '\\' || c == '/' || c == 'b' || c == 'f' || c == 'n' || c == 'r' || c == 't' || c == 'u'
+}
+
 type reader struct {
-	r   io.RuneReader
-	eof bool
-	unr int
-	off int64
+	r         io.RuneReader
+	eof       bool
+	unr       int
+	off       int64
+	nbytes    int64  // bytes consumed
+	size      int    // size of the last rune
+	buf       []byte // raw text capture buffer
+	capturing int
+	trace     func(Token)
+	replay    []Token
+	replaying bool
+	opt       *parserOptions
+	quoted    []byte // source text of the last string if raw capturing is enabled
+	comments  []rawComment
+	newline   bool   // newline seen since the last token
+	numBuf    []byte // number scratch buffer reused between tokens
+	strBuf    []byte // string scratch buffer reused between tokens
+	kwBuf     []byte // keyword scratch buffer reused between tokens
+	interned  map[string]string
+}
+
+const (
+	maxInternLen = 32   // longer strings are never interned
+	maxInterned  = 1024 // interning stops when the table is full
+)
+
+// intern returns the string equal to b reusing the previously allocated one for short strings like object keys
+func (r *reader) intern(b []byte) string {
+	if len(b) > maxInternLen {
+		return string(b)
+	}
+	if s, ok := r.interned[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if r.interned == nil {
+		r.interned = make(map[string]string)
+	}
+	if len(r.interned) < maxInterned {
+		r.interned[s] = s
+	}
+	return s
+}
+
+type rawComment struct {
+	text    string
+	pos     int64
+	newline bool // preceded by newline
+	block   bool
 }
 
 func newReader(r io.RuneReader) *reader {
-	return &reader{r: r, unr: -1}
+	return &reader{r: r, unr: -1, opt: &parserOptions{}}
+}
+
+// reset prepares the reader for the new input keeping options and scratch buffers
+func (r *reader) reset(src io.RuneReader) {
+	*r = reader{
+		r:        src,
+		unr:      -1,
+		opt:      r.opt,
+		trace:    r.trace,
+		buf:      r.buf[:0],
+		comments: r.comments[:0],
+		numBuf:   r.numBuf[:0],
+		strBuf:   r.strBuf[:0],
+		kwBuf:    r.kwBuf[:0],
+		interned: r.interned,
+	}
 }
 
 func (r *reader) pos() int64 { return r.off - 1 }
 
 func (r *reader) rune() (v rune, err error) {
 	if r.unr >= 0 {
-		v, r.unr, r.off = rune(r.unr), -1, r.off+1
+		v, r.unr, r.off, r.nbytes = rune(r.unr), -1, r.off+1, r.nbytes+int64(r.size)
+		r.record(v)
 		return
 	}
-	c, _, err := r.r.ReadRune()
+	c, size, err := r.r.ReadRune()
 	if err != nil {
 		if err == io.EOF {
 			r.eof = true
 		}
 		return 0, err
 	}
-	v, r.off = c, r.off+1
+	v, r.off, r.nbytes, r.size = c, r.off+1, r.nbytes+int64(size), size
+	r.record(v)
 	return
 }
 
+func (r *reader) record(c rune) {
+	if r.capturing > 0 {
+		var tmp [utf8.UTFMax]byte
+		n := utf8.EncodeRune(tmp[:], c)
+		r.buf = append(r.buf, tmp[:n]...)
+	}
+}
+
 func (r *reader) unread(b rune) {
-	r.unr, r.off = int(b), r.off-1
+	r.unr, r.off, r.nbytes = int(b), r.off-1, r.nbytes-int64(r.size)
+	if r.capturing > 0 {
+		r.buf = r.buf[:len(r.buf)-utf8.RuneLen(b)]
+	}
+}
+
+// capture starts recording the raw input text and returns the start offset in the capture buffer.
+// prefix is the already consumed text of the value. Captures may nest
+func (r *reader) capture(prefix string) int {
+	var start int
+	if r.capturing > 0 {
+		// the prefix is already recorded by the outer capture
+		start = len(r.buf) - len(prefix)
+	} else {
+		r.buf = append(r.buf[:0], prefix...)
+	}
+	r.capturing++
+	return start
+}
+
+// captured stops recording and returns the text recorded since start
+func (r *reader) captured(start int) []byte {
+	out := make([]byte, len(r.buf)-start)
+	copy(out, r.buf[start:])
+	r.capturing--
+	return out
 }
 
 func (r *reader) token() (token, error) {
+	if r.replaying {
+		return r.replayToken()
+	}
+	tok, err := r.scan()
+	if r.trace != nil {
+		if err == nil {
+			r.trace(exportToken(tok))
+		} else if serr, ok := err.(*SyntaxError); ok {
+			r.trace(Token{Kind: TokenError, Value: serr.Error(), Offset: serr.Offset})
+		}
+	}
+	return tok, err
+}
+
+func (r *reader) scan() (token, error) {
 	if r.eof {
-		return nil, io.EOF
+		return token{}, io.EOF
 	}
 	var (
 		c   rune
 		err error
 	)
-	for ok := true; ok; ok = isSpace(c) {
-		c, err = r.rune()
-		if err != nil {
-			return nil, err
+	r.newline = false
+	for {
+		if c, err = r.rune(); err != nil {
+			return token{}, err
+		}
+		if c == '\n' {
+			r.newline = true
+		}
+		if c == '/' && r.opt.comments {
+			if err = r.comment(); err != nil {
+				return token{}, err
+			}
+			continue
+		}
+		if !isSpace(c) {
+			break
 		}
 	}
 
 	pos := r.pos()
 	switch {
-	case c >= '0' && c <= '9' || c == '-' || c == '.':
-		// number
-		s := make([]rune, 0)
-		for {
-			s = append(s, c)
-			c, err = r.rune()
+	case c == '-' && r.opt.nonFinite:
+		c, err = r.rune()
+		if err != nil {
 			if err == io.EOF {
-				break
-			} else if err != nil {
-				return nil, err
-			} else if !isNum(c) {
-				r.unread(c)
-				break
+				return token{kind: tokNum, str: "-", p: pos}, nil
 			}
+			return token{}, err
 		}
-		return tokNum{tokString{string(s), pos}}, nil
+		r.unread(c)
+		if c == 'I' {
+			// -Infinity
+			s, err := r.keyword('-')
+			if err != nil {
+				return token{}, err
+			}
+			return token{kind: tokRes, str: s, p: pos}, nil
+		}
+		return r.number('-', pos)
+
+	case c >= '0' && c <= '9' || c == '-' || c == '.':
+		return r.number(c, pos)
 
-	case c == '"':
-		s, err := r.string()
+	case c == '"' || c == '\'' && r.opt.singleQuotes:
+		if r.opt.raw != nil {
+			start := r.capture(string(c))
+			s, err := r.string(c)
+			r.quoted = r.captured(start)
+			if err != nil {
+				return token{}, err
+			}
+			return token{kind: tokString, str: s, p: pos}, err
+		}
+		s, err := r.string(c)
 		if err != nil {
-			return nil, err
+			return token{}, err
 		}
-		return tokString{s, pos}, err
+		return token{kind: tokString, str: s, p: pos}, err
 
 	case c == '{' || c == '}' || c == '[' || c == ']' || c == ',' || c == ':':
-		return tokDelim{c, pos}, nil
-
-	case c >= 'a' && c <= 'z':
-		// keyword
-		var s strings.Builder
-		for {
-			s.WriteByte(byte(c))
-			c, err = r.rune()
-			if err == io.EOF {
-				break
-			} else if err != nil {
-				return nil, err
-			} else if !(c >= 'a' && c <= 'z') {
-				r.unread(c)
-				break
-			}
+		return token{kind: tokDelim, ch: c, p: pos}, nil
+
+	case c >= 'a' && c <= 'z' || r.opt.nonFinite && (c == 'N' || c == 'I'):
+		s, err := r.keyword(c)
+		if err != nil {
+			return token{}, err
 		}
-		return tokRes{tokString{s.String(), pos}}, nil
+		return token{kind: tokRes, str: s, p: pos}, nil
 
 	default:
-		return nil, fmt.Errorf("jtree: unexpected character '%c' at position %d", c, pos)
+		return token{}, syntaxErrorf(pos, "jtree: unexpected character '%c' at position %d", c, pos)
+	}
+}
+
+// comment reads the comment following the consumed slash
+func (r *reader) comment() error {
+	pos := r.pos()
+	c, err := r.rune()
+	if err != nil {
+		if err == io.EOF {
+			return syntaxErrorf(pos, "jtree: unexpected character '/' at position %d", pos)
+		}
+		return err
+	}
+	if c != '/' && c != '*' {
+		return syntaxErrorf(pos, "jtree: unexpected character '/' at position %d", pos)
+	}
+	s := []rune{'/', c}
+	block := c == '*'
+	for {
+		c, err = r.rune()
+		if err == io.EOF && !block {
+			break
+		} else if err == io.EOF {
+			return syntaxErrorf(pos, "jtree: unterminated comment at position %d", pos)
+		} else if err != nil {
+			return err
+		}
+		if c == '\n' && !block {
+			// let the scanner account the newline
+			r.unread(c)
+			break
+		}
+		s = append(s, c)
+		if block && c == '/' && s[len(s)-2] == '*' && len(s) > 3 {
+			break
+		}
 	}
+	r.comments = append(r.comments, rawComment{text: string(s), pos: pos, newline: r.newline, block: block})
+	return nil
 }
 
-func (r *reader) string() (string, error) {
+func (r *reader) number(c rune, pos int64) (token, error) {
+	var err error
+	s := r.numBuf[:0]
+	for {
+		// number characters are ASCII
+		s = append(s, byte(c))
+		c, err = r.rune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return token{}, err
+		} else if !isNum(c) {
+			r.unread(c)
+			break
+		}
+	}
+	r.numBuf = s
+	if r.trace != nil {
+		// the traced value may be retained
+		return token{kind: tokNum, str: string(s), p: pos}, nil
+	}
+	// the token is consumed before the next number overwrites the buffer
+	return token{kind: tokNum, str: bytesToString(s), p: pos}, nil
+}
+
+func (r *reader) isKeyword(c rune) bool {
+	return c >= 'a' && c <= 'z' || r.opt.nonFinite && c >= 'A' && c <= 'Z'
+}
+
+func (r *reader) keyword(c rune) (string, error) {
+	var err error
+	s := r.kwBuf[:0]
+	for {
+		// keyword characters are ASCII
+		s = append(s, byte(c))
+		c, err = r.rune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		} else if !r.isKeyword(c) {
+			r.unread(c)
+			break
+		}
+	}
+	r.kwBuf = s
+	// avoid allocations for valid keywords
+	switch string(s) {
+	case "true":
+		return "true", nil
+	case "false":
+		return "false", nil
+	case "null":
+		return "null", nil
+	case "NaN":
+		return "NaN", nil
+	case "Infinity":
+		return "Infinity", nil
+	case "-Infinity":
+		return "-Infinity", nil
+	}
+	return string(s), nil
+}
+
+func (r *reader) string(quote rune) (string, error) {
 	var (
 		esc  bool
 		ln   int
 		code uint
+		high rune = -1 // pending high surrogate escape
 	)
-	u16 := make([]uint16, 0)
+	buf := r.strBuf[:0]
 	for {
 		c, err := r.rune()
 		if err != nil {
@@ -165,16 +400,43 @@ func (r *reader) string() (string, error) {
 			case c >= 'A' && c <= 'F':
 				hex = uint(c) - 'A' + 0xa
 			default:
-				return "", fmt.Errorf("jtree: invalid hexadecimal digit '%c' at position %d", c, r.pos())
+				return "", syntaxErrorf(r.pos(), "jtree: invalid hexadecimal digit '%c' at position %d", c, r.pos())
 			}
 			code = code<<4 | hex
 			ln--
 			if ln == 0 {
-				u16 = append(u16, uint16(code))
+				u := rune(code)
 				code = 0
+				if high >= 0 {
+					if utf16.IsSurrogate(u) && u >= 0xdc00 {
+						buf = utf8.AppendRune(buf, utf16.DecodeRune(high, u))
+						high = -1
+						continue
+					}
+					buf = utf8.AppendRune(buf, utf8.RuneError)
+					high = -1
+				}
+				switch {
+				case u >= 0xd800 && u < 0xdc00:
+					high = u
+				case utf16.IsSurrogate(u):
+					buf = utf8.AppendRune(buf, utf8.RuneError)
+				default:
+					buf = utf8.AppendRune(buf, u)
+				}
 			}
-		} else if esc {
+			continue
+		}
+		if high >= 0 && !(esc && (c == 'u' || c == 'x')) && !(!esc && c == '\\') {
+			// unpaired high surrogate
+			buf = utf8.AppendRune(buf, utf8.RuneError)
+			high = -1
+		}
+		if esc {
 			esc = false
+			if r.opt.strictEscapes && !isEscape(c) && !(c == '\'' && r.opt.singleQuotes) {
+				return "", syntaxErrorf(r.pos(), "jtree: invalid escape sequence '\\%c' at position %d", c, r.pos())
+			}
 			if c == 'u' {
 				ln = 4
 			} else if c == 'x' {
@@ -192,16 +454,20 @@ func (r *reader) string() (string, error) {
 				case 't':
 					c = '\t'
 				}
-				u16 = append(u16, utf16.Encode([]rune{c})...)
+				buf = utf8.AppendRune(buf, c)
 			}
 		} else if c == '\\' {
 			esc = true
 		} else {
-			if c == '"' {
+			if c == quote {
 				break
 			}
-			u16 = append(u16, utf16.Encode([]rune{c})...)
+			if c < 0x20 && r.opt.noControlChars {
+				return "", syntaxErrorf(r.pos(), "jtree: unescaped control character %U at position %d", c, r.pos())
+			}
+			buf = utf8.AppendRune(buf, c)
 		}
 	}
-	return string(utf16.Decode(u16)), nil
+	r.strBuf = buf
+	return r.intern(buf), nil
 }
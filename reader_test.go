@@ -20,18 +20,34 @@ func TestReader(t *testing.T) {
 		tokens = append(tokens, tok)
 	}
 	require.Equal(t, []token{
-		tokDelim{'{', 0},
-		tokString{"str", 1},
-		tokDelim{':', 6},
-		tokString{"\\zzz\t\n\"xxx\U0001D11Efff\u1234привет", 7},
-		tokDelim{',', 51},
-		tokString{"num", 52},
-		tokDelim{':', 57},
-		tokNum{tokString{"-0.123e-5", 58}},
-		tokDelim{',', 67},
-		tokString{"bool", 68},
-		tokDelim{':', 74},
-		tokRes{tokString{"false", 75}},
-		tokDelim{'}', 80},
+		{kind: tokDelim, ch: '{', p: 0},
+		{kind: tokString, str: "str", p: 1},
+		{kind: tokDelim, ch: ':', p: 6},
+		{kind: tokString, str: "\\zzz\t\n\"xxx\U0001D11Efff\u1234привет", p: 7},
+		{kind: tokDelim, ch: ',', p: 51},
+		{kind: tokString, str: "num", p: 52},
+		{kind: tokDelim, ch: ':', p: 57},
+		{kind: tokNum, str: "-0.123e-5", p: 58},
+		{kind: tokDelim, ch: ',', p: 67},
+		{kind: tokString, str: "bool", p: 68},
+		{kind: tokDelim, ch: ':', p: 74},
+		{kind: tokRes, str: "false", p: 75},
+		{kind: tokDelim, ch: '}', p: 80},
 	}, tokens)
 }
+
+func TestReaderAllocs(t *testing.T) {
+	src := `[{"key": true}, {"key": false}, null, [], {}]`
+	sr := strings.NewReader(src)
+	r := newReader(sr)
+	allocs := testing.AllocsPerRun(10, func() {
+		sr.Reset(src)
+		r.reset(sr)
+		for {
+			if _, err := r.token(); err != nil {
+				break
+			}
+		}
+	})
+	require.Zero(t, allocs)
+}
@@ -3,68 +3,286 @@ package jtree
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-// TypeRegistry stores uses interface type constructors (decoders)
+// TypeRegistry stores user type constructors (decoders)
 type TypeRegistry struct {
-	types map[reflect.Type]interface{}
-	mtx   sync.RWMutex
+	types     map[reflect.Type]interface{}
+	variants  map[reflect.Type]map[string]reflect.Type
+	decoders  map[string]FieldDecoder
+	coercions []Coercion
+	fallback  FallbackFunc
+	nulls     map[reflect.Type]func(*Context) (reflect.Value, error)
+	parent    *TypeRegistry
+	mtx       sync.RWMutex
 }
 
 // NewTypeRegistry returns new empty TypeRegistry
 func NewTypeRegistry() *TypeRegistry {
 	return &TypeRegistry{
-		types: make(map[reflect.Type]interface{}),
+		types:    make(map[reflect.Type]interface{}),
+		variants: make(map[reflect.Type]map[string]reflect.Type),
+		decoders: make(map[string]FieldDecoder),
+		nulls:    make(map[reflect.Type]func(*Context) (reflect.Value, error)),
 	}
 }
 
-var ctxType = reflect.TypeOf((*Context)(nil))
+// NewChildTypeRegistry returns new empty TypeRegistry falling back to parent for types, variants and field decoders
+// not registered in it. Entries of the child registry shadow parent's ones
+func NewChildTypeRegistry(parent *TypeRegistry) *TypeRegistry {
+	r := NewTypeRegistry()
+	r.parent = parent
+	return r
+}
+
+var (
+	ctxType     = reflect.TypeOf((*Context)(nil))
+	opSliceType = reflect.TypeOf([]Option(nil))
+)
 
-// RegisterType registers user interface type. The argument is a constructor function of type `func(Node, []Option) (UserType, error)`.
-// It panics if any other type is passed
+// RegisterType registers user type. The argument is a constructor function of type `func(Node, *Context) (UserType, error)`.
+// UserType is either an interface or a concrete non pointer type. Constructors of concrete types take precedence
+// over DecodeJSON and UnmarshalJSON methods, so third party types can be decoded in a custom way.
+// The extended signature `func(Node, *Context, []Option) (UserType, error)` receives options active at the call site
+// like string or encoding ones which can be passed to nested Decode calls in place of OpCtx.
+// It panics if any other type is passed or the type is already registered
 func (r *TypeRegistry) RegisterType(fn interface{}) {
+	if err := r.TryRegisterType(fn); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryRegisterType is the RegisterType variant returning an error instead of panicking
+func (r *TypeRegistry) TryRegisterType(fn interface{}) error {
 	ft := reflect.TypeOf(fn)
-	if ft.Kind() != reflect.Func {
-		panic(fmt.Sprintf("jtree: function expected: %v", ft))
+	if ft == nil || ft.Kind() != reflect.Func {
+		return fmt.Errorf("jtree: function expected: %v", ft)
 	}
-	if ft.NumIn() != 2 || ft.In(0) != nodeType || ft.In(1) != ctxType || ft.NumOut() != 2 || ft.Out(1) != errorType {
-		panic(fmt.Sprintf("jtree: invalid signature: %v", ft))
+	if ft.NumIn() != 2 && (ft.NumIn() != 3 || ft.In(2) != opSliceType) || ft.In(0) != nodeType || ft.In(1) != ctxType ||
+		ft.NumOut() != 2 || ft.Out(1) != errorType || ft.IsVariadic() {
+		return fmt.Errorf("jtree: invalid signature: %v", ft)
+	}
+	return r.register(ft.Out(0), fn)
+}
+
+// RegisterTypeFor registers the constructor of user type T like RegisterType with the signature checked at compile time.
+// nil r means the global registry
+func RegisterTypeFor[T any](r *TypeRegistry, fn func(Node, *Context) (T, error)) {
+	if err := TryRegisterTypeFor(r, fn); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryRegisterTypeFor is the RegisterTypeFor variant returning an error instead of panicking
+func TryRegisterTypeFor[T any](r *TypeRegistry, fn func(Node, *Context) (T, error)) error {
+	if r == nil {
+		r = DefaultTypeRegistry()
 	}
-	t := ft.Out(0)
-	if t.Kind() != reflect.Interface {
-		panic(fmt.Sprintf("jtree: user type must be an interface: %v", t))
+	return r.register(reflect.TypeOf((*T)(nil)).Elem(), fn)
+}
+
+func (r *TypeRegistry) register(t reflect.Type, fn interface{}) error {
+	if t.Kind() == reflect.Ptr {
+		return fmt.Errorf("jtree: user type must not be a pointer: %v", t)
 	}
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 	if _, ok := r.types[t]; ok {
-		panic(fmt.Sprintf("jtree: duplicate user type: %v", t))
+		return fmt.Errorf("jtree: duplicate user type: %v", t)
 	}
 	r.types[t] = fn
+	return nil
 }
 
-func (r *TypeRegistry) call(t reflect.Type, n Node, ctx *Context) (reflect.Value, error) {
-	r.mtx.RLock()
-	f, ok := r.types[t]
-	r.mtx.RUnlock()
-	if !ok {
+func (r *TypeRegistry) constructor(t reflect.Type) interface{} {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		f, ok := r.types[t]
+		r.mtx.RUnlock()
+		if ok {
+			return f
+		}
+	}
+	return nil
+}
+
+func (r *TypeRegistry) call(t reflect.Type, n Node, opt *options) (reflect.Value, error) {
+	f := r.constructor(t)
+	if f == nil {
 		return reflect.Value{}, nil
 	}
-	out := reflect.ValueOf(f).Call([]reflect.Value{reflect.ValueOf(n), reflect.ValueOf(ctx)})
+	fn := reflect.ValueOf(f)
+	args := []reflect.Value{reflect.ValueOf(n), reflect.ValueOf(opt.ctx())}
+	if fn.Type().NumIn() == 3 {
+		args = append(args, reflect.ValueOf([]Option{opCopy(opt)}))
+	}
+	out := fn.Call(args)
 	if !out[1].IsNil() {
 		return reflect.Value{}, out[1].Interface().(error)
 	}
 	return out[0], nil
 }
 
+// RegisterVariant registers the concrete type of the prototype value v as the variant of the interface type pointed
+// by iface, selected by the discriminator value name. Variants are used with `json:"field,union=discriminator"`
+// struct tags, where the discriminator is the name of the sibling object member holding the variant name.
+// It panics if iface is not a pointer to an interface or v doesn't implement it
+func (r *TypeRegistry) RegisterVariant(iface interface{}, name string, v interface{}) {
+	pt := reflect.TypeOf(iface)
+	if pt == nil || pt.Kind() != reflect.Ptr || pt.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("jtree: pointer to interface expected: %v", pt))
+	}
+	it, vt := pt.Elem(), reflect.TypeOf(v)
+	if vt == nil || !vt.Implements(it) {
+		panic(fmt.Sprintf("jtree: %v doesn't implement %v", vt, it))
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	m, ok := r.variants[it]
+	if !ok {
+		m = make(map[string]reflect.Type)
+		r.variants[it] = m
+	}
+	if _, ok := m[name]; ok {
+		panic(fmt.Sprintf("jtree: duplicate variant %q: %v", name, it))
+	}
+	m[name] = vt
+}
+
+func (r *TypeRegistry) variant(t reflect.Type, name string) reflect.Type {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		vt := r.variants[t][name]
+		r.mtx.RUnlock()
+		if vt != nil {
+			return vt
+		}
+	}
+	return nil
+}
+
+// FallbackFunc is called for interface destinations having no registered constructor. It returns the value
+// implementing the target type and true, or false to continue with the default behaviour
+type FallbackFunc func(t reflect.Type, node Node, ctx *Context) (value interface{}, ok bool, err error)
+
+// SetFallback sets the fallback function of the registry. The fallback of the parent registry is called if the one
+// of the child declines the node
+func (r *TypeRegistry) SetFallback(fn FallbackFunc) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.fallback = fn
+}
+
+func (r *TypeRegistry) callFallback(t reflect.Type, n Node, ctx *Context) (reflect.Value, error) {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		fn := r.fallback
+		r.mtx.RUnlock()
+		if fn == nil {
+			continue
+		}
+		v, ok, err := fn(t, n, ctx)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if !ok {
+			continue
+		}
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		val := reflect.ValueOf(v)
+		if !val.Type().AssignableTo(t) {
+			return reflect.Value{}, fmt.Errorf("jtree: fallback returned %v for %v", val.Type(), t)
+		}
+		return val, nil
+	}
+	return reflect.Value{}, nil
+}
+
+// RegisterNullFor registers the function producing the value of type T stored in place of null instead of
+// the zero value. nil r means the global registry
+func RegisterNullFor[T any](r *TypeRegistry, fn func(ctx *Context) (T, error)) {
+	if r == nil {
+		r = DefaultTypeRegistry()
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.nulls[t]; ok {
+		panic(fmt.Sprintf("jtree: duplicate null handler: %v", t))
+	}
+	r.nulls[t] = func(ctx *Context) (reflect.Value, error) {
+		v, err := fn(ctx)
+		return reflect.ValueOf(&v).Elem(), err
+	}
+}
+
+func (r *TypeRegistry) nullHandler(t reflect.Type) func(*Context) (reflect.Value, error) {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		fn := r.nulls[t]
+		r.mtx.RUnlock()
+		if fn != nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+// FieldDecoder decodes the node into the struct field value out. ctx must be passed to nested Decode calls using OpCtx
+type FieldDecoder func(node Node, out reflect.Value, ctx *Context) error
+
+// RegisterFieldDecoder registers the named field decoder referenced by `json:"field,decoder=name"` struct tags
+func (r *TypeRegistry) RegisterFieldDecoder(name string, fn FieldDecoder) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.decoders[name]; ok {
+		panic(fmt.Sprintf("jtree: duplicate field decoder: %v", name))
+	}
+	r.decoders[name] = fn
+}
+
+func (r *TypeRegistry) fieldDecoder(name string) FieldDecoder {
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		fn := r.decoders[name]
+		r.mtx.RUnlock()
+		if fn != nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+// RegisterFieldDecoder registers the named field decoder in the global registry
+func RegisterFieldDecoder(name string, fn FieldDecoder) {
+	DefaultTypeRegistry().RegisterFieldDecoder(name, fn)
+}
+
+// RegisterVariant registers the variant of the interface type in the global registry
+func RegisterVariant(iface interface{}, name string, v interface{}) {
+	DefaultTypeRegistry().RegisterVariant(iface, name, v)
+}
+
 // RegisterType registers user interface type in the global registry
 func RegisterType(fn interface{}) {
-	defaultTypeRegistry.RegisterType(fn)
+	DefaultTypeRegistry().RegisterType(fn)
+}
+
+// TryRegisterType registers user type in the global registry returning an error instead of panicking
+func TryRegisterType(fn interface{}) error {
+	return DefaultTypeRegistry().TryRegisterType(fn)
 }
 
 // EncodingRegistry stores user encoding schemes
 type EncodingRegistry struct {
 	encodings map[string]Encoding
+	short     map[string][]string // qualified names by their last element
+	parent    *EncodingRegistry
 	mtx       sync.RWMutex
 }
 
@@ -72,35 +290,193 @@ type EncodingRegistry struct {
 func NewEncodingRegistry() *EncodingRegistry {
 	return &EncodingRegistry{
 		encodings: make(map[string]Encoding),
+		short:     make(map[string][]string),
 	}
 }
 
-// RegisterEncoding registers custom encoding scheme under provided name
+// NewChildEncodingRegistry returns new empty EncodingRegistry falling back to parent for encodings
+// not registered in it. Entries of the child registry shadow parent's ones
+func NewChildEncodingRegistry(parent *EncodingRegistry) *EncodingRegistry {
+	r := NewEncodingRegistry()
+	r.parent = parent
+	return r
+}
+
+// RegisterEncoding registers custom encoding scheme under provided name. The name can be qualified with a slash
+// separated namespace like "mycorp/hex32" to avoid collisions between independent libraries. A qualified name is
+// always resolved exactly while a short name like "hex32" resolves to the encoding registered under it as is or,
+// if there is none, to the only encoding registered under a qualified name with the same last element.
+// Registries are searched from the child to the parent at every step. Names ending with field tag keywords
+// like "omitempty" or "required" are rejected
 func (r *EncodingRegistry) RegisterEncoding(name string, enc Encoding) {
+	if err := r.TryRegisterEncoding(name, enc); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryRegisterEncoding is the RegisterEncoding variant returning an error instead of panicking
+func (r *EncodingRegistry) TryRegisterEncoding(name string, enc Encoding) error {
+	if enc == nil {
+		return fmt.Errorf("jtree: nil encoding: %v", name)
+	}
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//") ||
+		strings.ContainsAny(name, ",[]") {
+		return fmt.Errorf("jtree: invalid encoding name: %q", name)
+	}
+	short, qualified := shortName(name)
+	if isTagKeyword(short) {
+		return fmt.Errorf("jtree: reserved encoding name: %q", name)
+	}
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 	if _, ok := r.encodings[name]; ok {
-		panic(fmt.Sprintf("jtree: duplicate encoding: %v", name))
+		return fmt.Errorf("jtree: duplicate encoding: %v", name)
 	}
 	r.encodings[name] = enc
+	if qualified {
+		r.short[short] = append(r.short[short], name)
+	}
+	encodingsGen.Add(1)
+	return nil
+}
+
+// shortName returns the last element of the slash separated name
+func shortName(name string) (short string, qualified bool) {
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		return name[i+1:], true
+	}
+	return name, false
+}
+
+// unregister removes the encoding. Must be called with the lock held
+func (r *EncodingRegistry) unregister(name string) bool {
+	if _, ok := r.encodings[name]; !ok {
+		return false
+	}
+	delete(r.encodings, name)
+	encodingsGen.Add(1)
+	if short, qualified := shortName(name); qualified {
+		names := r.short[short]
+		for i, n := range names {
+			if n == name {
+				names = append(names[:i:i], names[i+1:]...)
+				break
+			}
+		}
+		if len(names) == 0 {
+			delete(r.short, short)
+		} else {
+			r.short[short] = names
+		}
+	}
+	return true
 }
 
 func (r *EncodingRegistry) get(name string) Encoding {
-	r.mtx.RLock()
-	e := r.encodings[name]
-	r.mtx.RUnlock()
-	return e
+	if isTagKeyword(name) {
+		return nil
+	}
+	for p := r; p != nil; p = p.parent {
+		p.mtx.RLock()
+		e := p.encodings[name]
+		p.mtx.RUnlock()
+		if e != nil {
+			return e
+		}
+	}
+	if strings.Contains(name, "/") {
+		return nil
+	}
+	// unique namespaced entry
+	for p := r; p != nil; p = p.parent {
+		p.mtx.RLock()
+		names := p.short[name]
+		var e Encoding
+		if len(names) == 1 {
+			e = p.encodings[names[0]]
+		}
+		p.mtx.RUnlock()
+		switch {
+		case len(names) == 1:
+			return e
+		case len(names) > 1:
+			return nil // ambiguous
+		}
+	}
+	return nil
+}
+
+// EncodeString encodes data using the named encoding producing the string node. It's the encoding path counterpart
+// of encoding name tags and honors custom registered encodings
+func (r *EncodingRegistry) EncodeString(name string, data []byte) (String, error) {
+	enc := r.get(name)
+	if enc == nil {
+		return "", fmt.Errorf("jtree: unknown encoding '%s'", name)
+	}
+	return String(enc.Encode(data)), nil
+}
+
+// EncodeString encodes data using the encoding named in the global registry
+func EncodeString(name string, data []byte) (String, error) {
+	return DefaultEncodingRegistry().EncodeString(name, data)
 }
 
 // RegisterEncoding registers custom encoding scheme under provided name in the global registry
 func RegisterEncoding(name string, enc Encoding) {
-	defaultEncodingRegistry.RegisterEncoding(name, enc)
+	DefaultEncodingRegistry().RegisterEncoding(name, enc)
 }
 
-var defaultTypeRegistry = NewTypeRegistry()
-var defaultEncodingRegistry = NewEncodingRegistry()
+// TryRegisterEncoding registers the encoding in the global registry returning an error instead of panicking
+func TryRegisterEncoding(name string, enc Encoding) error {
+	return DefaultEncodingRegistry().TryRegisterEncoding(name, enc)
+}
+
+var (
+	defaultTypeRegistry     atomic.Pointer[TypeRegistry]
+	defaultEncodingRegistry atomic.Pointer[EncodingRegistry]
+	encodingsGen            atomic.Uint64 // incremented on every change of any encoding registry
+)
+
+// NewBuiltinEncodingRegistry returns new EncodingRegistry populated with built-in encodings
+func NewBuiltinEncodingRegistry() *EncodingRegistry {
+	r := NewEncodingRegistry()
+	r.RegisterEncoding("base64", Base64)
+	r.RegisterEncoding("base64raw", Base64Raw)
+	r.RegisterEncoding("base64url", Base64URL)
+	r.RegisterEncoding("base64rawurl", Base64RawURL)
+	r.RegisterEncoding("base32", Base32)
+	r.RegisterEncoding("base32hex", Base32Hex)
+	r.RegisterEncoding("base58", Base58)
+	r.RegisterEncoding("hex", Hex)
+	r.RegisterEncoding("hex0x", Hex0x)
+	return r
+}
+
+// DefaultTypeRegistry returns the global type registry used unless OpTypes is specified
+func DefaultTypeRegistry() *TypeRegistry { return defaultTypeRegistry.Load() }
+
+// SetDefaultTypeRegistry atomically replaces the global type registry and returns the previous one.
+// It panics if r is nil
+func SetDefaultTypeRegistry(r *TypeRegistry) *TypeRegistry {
+	if r == nil {
+		panic("jtree: nil type registry")
+	}
+	return defaultTypeRegistry.Swap(r)
+}
+
+// DefaultEncodingRegistry returns the global encoding registry used unless OpEncodings is specified
+func DefaultEncodingRegistry() *EncodingRegistry { return defaultEncodingRegistry.Load() }
+
+// SetDefaultEncodingRegistry atomically replaces the global encoding registry and returns the previous one.
+// Use NewBuiltinEncodingRegistry or NewChildEncodingRegistry to keep built-in encodings. It panics if r is nil
+func SetDefaultEncodingRegistry(r *EncodingRegistry) *EncodingRegistry {
+	if r == nil {
+		panic("jtree: nil encoding registry")
+	}
+	return defaultEncodingRegistry.Swap(r)
+}
 
 func init() {
-	RegisterEncoding("base64", Base64)
-	RegisterEncoding("hex", Hex)
+	defaultTypeRegistry.Store(NewTypeRegistry())
+	defaultEncodingRegistry.Store(NewBuiltinEncodingRegistry())
 }
@@ -0,0 +1,228 @@
+package jtree
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Clone returns a copy of the registry sharing the parent with the original one
+func (r *TypeRegistry) Clone() *TypeRegistry {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	out := NewChildTypeRegistry(r.parent)
+	for t, fn := range r.types {
+		out.types[t] = fn
+	}
+	for t, m := range r.variants {
+		vm := make(map[string]reflect.Type, len(m))
+		for name, vt := range m {
+			vm[name] = vt
+		}
+		out.variants[t] = vm
+	}
+	for name, fn := range r.decoders {
+		out.decoders[name] = fn
+	}
+	for t, fn := range r.nulls {
+		out.nulls[t] = fn
+	}
+	out.coercions = append([]Coercion(nil), r.coercions...)
+	out.fallback = r.fallback
+	return out
+}
+
+// Restore atomically replaces the content of the registry with the content of the snapshot previously
+// obtained using Clone. The snapshot is copied and can be reused. It returns an error if the registry is
+// one of the snapshot's parents
+func (r *TypeRegistry) Restore(snapshot *TypeRegistry) error {
+	tmp := snapshot.Clone()
+	for p := tmp.parent; p != nil; p = p.parent {
+		if p == r {
+			return errors.New("jtree: snapshot of a child registry can't be restored into its parent")
+		}
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.types, r.variants, r.decoders, r.coercions, r.fallback, r.nulls, r.parent =
+		tmp.types, tmp.variants, tmp.decoders, tmp.coercions, tmp.fallback, tmp.nulls, tmp.parent
+	return nil
+}
+
+// Unregister removes the constructor of the user type t and reports whether it was registered
+func (r *TypeRegistry) Unregister(t reflect.Type) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	_, ok := r.types[t]
+	delete(r.types, t)
+	return ok
+}
+
+// UnregisterVariant removes the variant of the interface type pointed by iface and reports whether it was registered
+func (r *TypeRegistry) UnregisterVariant(iface interface{}, name string) bool {
+	pt := reflect.TypeOf(iface)
+	if pt == nil || pt.Kind() != reflect.Ptr || pt.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("jtree: pointer to interface expected: %v", pt))
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	m := r.variants[pt.Elem()]
+	_, ok := m[name]
+	delete(m, name)
+	return ok
+}
+
+// UnregisterFieldDecoder removes the named field decoder and reports whether it was registered
+func (r *TypeRegistry) UnregisterFieldDecoder(name string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	_, ok := r.decoders[name]
+	delete(r.decoders, name)
+	return ok
+}
+
+// Clone returns a copy of the registry sharing the parent with the original one
+func (r *EncodingRegistry) Clone() *EncodingRegistry {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	out := NewChildEncodingRegistry(r.parent)
+	for name, e := range r.encodings {
+		out.encodings[name] = e
+	}
+	for short, names := range r.short {
+		out.short[short] = append([]string(nil), names...)
+	}
+	return out
+}
+
+// Restore atomically replaces the content of the registry with the content of the snapshot previously
+// obtained using Clone. The snapshot is copied and can be reused. It returns an error if the registry is
+// one of the snapshot's parents
+func (r *EncodingRegistry) Restore(snapshot *EncodingRegistry) error {
+	tmp := snapshot.Clone()
+	for p := tmp.parent; p != nil; p = p.parent {
+		if p == r {
+			return errors.New("jtree: snapshot of a child registry can't be restored into its parent")
+		}
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.encodings, r.short, r.parent = tmp.encodings, tmp.short, tmp.parent
+	encodingsGen.Add(1)
+	return nil
+}
+
+// Unregister removes the named encoding and reports whether it was registered
+func (r *EncodingRegistry) Unregister(name string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.unregister(name)
+}
+
+// SnapshotTypes returns a copy of the global type registry suitable for RestoreTypes
+func SnapshotTypes() *TypeRegistry { return DefaultTypeRegistry().Clone() }
+
+// RestoreTypes atomically replaces the content of the global type registry with the snapshot
+func RestoreTypes(snapshot *TypeRegistry) error { return DefaultTypeRegistry().Restore(snapshot) }
+
+// SnapshotEncodings returns a copy of the global encoding registry suitable for RestoreEncodings
+func SnapshotEncodings() *EncodingRegistry { return DefaultEncodingRegistry().Clone() }
+
+// RestoreEncodings atomically replaces the content of the global encoding registry with the snapshot
+func RestoreEncodings(snapshot *EncodingRegistry) error {
+	return DefaultEncodingRegistry().Restore(snapshot)
+}
+
+// UnregisterType removes the user type from the global registry
+func UnregisterType(t reflect.Type) bool { return DefaultTypeRegistry().Unregister(t) }
+
+// UnregisterEncoding removes the encoding from the global registry
+func UnregisterEncoding(name string) bool { return DefaultEncodingRegistry().Unregister(name) }
+
+// Types returns user types having constructors registered in the registry or its parents sorted by name
+func (r *TypeRegistry) Types() []reflect.Type {
+	seen := make(map[reflect.Type]bool)
+	var out []reflect.Type
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		for t := range r.types {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+		r.mtx.RUnlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
+
+// Constructor returns the constructor function of the user type t
+func (r *TypeRegistry) Constructor(t reflect.Type) (interface{}, bool) {
+	fn := r.constructor(t)
+	return fn, fn != nil
+}
+
+// Variants returns the variant types of the interface type t keyed by their names
+func (r *TypeRegistry) Variants(t reflect.Type) map[string]reflect.Type {
+	out := make(map[string]reflect.Type)
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		for name, vt := range r.variants[t] {
+			if _, ok := out[name]; !ok {
+				out[name] = vt
+			}
+		}
+		r.mtx.RUnlock()
+	}
+	return out
+}
+
+// FieldDecoders returns sorted names of registered field decoders
+func (r *TypeRegistry) FieldDecoders() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		for name := range r.decoders {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+		r.mtx.RUnlock()
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Names returns sorted names of encodings registered in the registry or its parents
+func (r *EncodingRegistry) Names() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		for name := range r.encodings {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+		r.mtx.RUnlock()
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Encoding returns the named encoding
+func (r *EncodingRegistry) Encoding(name string) (Encoding, bool) {
+	e := r.get(name)
+	return e, e != nil
+}
+
+// DefaultTypes returns user types registered in the global registry
+func DefaultTypes() []reflect.Type { return DefaultTypeRegistry().Types() }
+
+// DefaultEncodings returns names of encodings registered in the global registry
+func DefaultEncodings() []string { return DefaultEncodingRegistry().Names() }
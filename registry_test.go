@@ -0,0 +1,170 @@
+package jtree_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+type upperEncoding struct{}
+
+func (upperEncoding) Encode(src []byte) []byte { return src }
+
+func (upperEncoding) Decode(src []byte) ([]byte, error) {
+	out := make([]byte, len(src))
+	for i, c := range src {
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		out[i] = c
+	}
+	return out, nil
+}
+
+func TestRegistryParent(t *testing.T) {
+	parent := jtree.NewTypeRegistry()
+	jtree.RegisterTypeFor(parent, func(n jtree.Node, ctx *jtree.Context) (celsius, error) { return 1, nil })
+	child := jtree.NewChildTypeRegistry(parent)
+
+	encParent := jtree.NewEncodingRegistry()
+	encParent.RegisterEncoding("upper", upperEncoding{})
+	encChild := jtree.NewChildEncodingRegistry(encParent)
+
+	var dest struct {
+		C    celsius `json:"c"`
+		Data []byte  `json:"data,upper"`
+	}
+	src := mustParse(t, `{"c": 0, "data": "abc"}`)
+	if assert.NoError(t, src.Decode(&dest, jtree.OpTypes(child), jtree.OpEncodings(encChild))) {
+		assert.Equal(t, celsius(1), dest.C)
+		assert.Equal(t, []byte("ABC"), dest.Data)
+	}
+
+	// the child entry shadows the parent one
+	jtree.RegisterTypeFor(child, func(n jtree.Node, ctx *jtree.Context) (celsius, error) { return 2, nil })
+	if assert.NoError(t, src.Decode(&dest, jtree.OpTypes(child), jtree.OpEncodings(encChild))) {
+		assert.Equal(t, celsius(2), dest.C)
+	}
+	if assert.NoError(t, src.Decode(&dest, jtree.OpTypes(parent), jtree.OpEncodings(encChild))) {
+		assert.Equal(t, celsius(1), dest.C)
+	}
+}
+
+func TestRegistrySnapshot(t *testing.T) {
+	snap := jtree.SnapshotEncodings()
+	jtree.RegisterEncoding("upper", upperEncoding{})
+	var dest struct {
+		Data []byte `json:"data,upper"`
+	}
+	if assert.NoError(t, mustParse(t, `{"data": "abc"}`).Decode(&dest)) {
+		assert.Equal(t, []byte("ABC"), dest.Data)
+	}
+	assert.True(t, jtree.UnregisterEncoding("upper"))
+	assert.False(t, jtree.UnregisterEncoding("upper"))
+	jtree.RegisterEncoding("upper", upperEncoding{}) // doesn't panic after removal
+	assert.NoError(t, jtree.RestoreEncodings(snap))
+	jtree.RegisterEncoding("upper", upperEncoding{}) // doesn't panic after restoring
+	assert.NoError(t, jtree.RestoreEncodings(snap))
+
+	reg := jtree.NewTypeRegistry()
+	jtree.RegisterTypeFor(reg, func(n jtree.Node, ctx *jtree.Context) (celsius, error) { return 1, nil })
+	clone := reg.Clone()
+	assert.True(t, reg.Unregister(reflect.TypeOf(celsius(0))))
+
+	var c celsius
+	if assert.NoError(t, mustParse(t, `2`).Decode(&c, jtree.OpTypes(clone))) {
+		assert.Equal(t, celsius(1), c)
+	}
+	if assert.NoError(t, mustParse(t, `2`).Decode(&c, jtree.OpTypes(reg))) {
+		assert.Equal(t, celsius(2), c)
+	}
+	assert.NoError(t, reg.Restore(clone))
+	if assert.NoError(t, mustParse(t, `2`).Decode(&c, jtree.OpTypes(reg))) {
+		assert.Equal(t, celsius(1), c)
+	}
+
+	// restoring a descendant would make the registry its own parent
+	child := jtree.NewChildTypeRegistry(jtree.NewChildTypeRegistry(reg))
+	assert.EqualError(t, reg.Restore(child), "jtree: snapshot of a child registry can't be restored into its parent")
+	assert.EqualError(t, reg.Restore(child.Clone()), "jtree: snapshot of a child registry can't be restored into its parent")
+	encodings := jtree.NewEncodingRegistry()
+	assert.EqualError(t, encodings.Restore(jtree.NewChildEncodingRegistry(encodings)), "jtree: snapshot of a child registry can't be restored into its parent")
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(celsius(0))}, child.Types())
+}
+
+func TestRegistryIntrospection(t *testing.T) {
+	assert.Subset(t, jtree.DefaultEncodings(), []string{"base64", "base64url", "base32", "base58", "hex", "hex0x"})
+	assert.Contains(t, jtree.DefaultTypes(), reflect.TypeOf((*UserType)(nil)).Elem())
+
+	parent := jtree.NewTypeRegistry()
+	jtree.RegisterTypeFor(parent, func(n jtree.Node, ctx *jtree.Context) (celsius, error) { return 1, nil })
+	parent.RegisterVariant((*shape)(nil), "circle", (*circle)(nil))
+	parent.RegisterFieldDecoder("b", func(jtree.Node, reflect.Value, *jtree.Context) error { return nil })
+	child := jtree.NewChildTypeRegistry(parent)
+	child.RegisterVariant((*shape)(nil), "square", square{})
+	child.RegisterFieldDecoder("a", func(jtree.Node, reflect.Value, *jtree.Context) error { return nil })
+
+	ct := reflect.TypeOf(celsius(0))
+	assert.Equal(t, []reflect.Type{ct}, child.Types())
+	fn, ok := child.Constructor(ct)
+	assert.True(t, ok)
+	assert.IsType(t, func(jtree.Node, *jtree.Context) (celsius, error) { return 0, nil }, fn)
+	_, ok = child.Constructor(reflect.TypeOf(0))
+	assert.False(t, ok)
+	assert.Equal(t, map[string]reflect.Type{
+		"circle": reflect.TypeOf((*circle)(nil)),
+		"square": reflect.TypeOf(square{}),
+	}, child.Variants(reflect.TypeOf((*shape)(nil)).Elem()))
+	assert.Equal(t, []string{"a", "b"}, child.FieldDecoders())
+
+	enc := jtree.NewChildEncodingRegistry(jtree.NewEncodingRegistry())
+	enc.RegisterEncoding("upper", upperEncoding{})
+	assert.Equal(t, []string{"upper"}, enc.Names())
+	e, ok := enc.Encoding("upper")
+	assert.True(t, ok)
+	assert.Equal(t, upperEncoding{}, e)
+}
+
+func TestSetDefaultRegistry(t *testing.T) {
+	types := jtree.NewTypeRegistry()
+	jtree.RegisterTypeFor(types, func(n jtree.Node, ctx *jtree.Context) (celsius, error) { return 5, nil })
+	prevTypes := jtree.SetDefaultTypeRegistry(types)
+	defer jtree.SetDefaultTypeRegistry(prevTypes)
+
+	enc := jtree.NewChildEncodingRegistry(jtree.NewBuiltinEncodingRegistry())
+	enc.RegisterEncoding("upper", upperEncoding{})
+	prevEnc := jtree.SetDefaultEncodingRegistry(enc)
+	defer jtree.SetDefaultEncodingRegistry(prevEnc)
+
+	assert.Same(t, types, jtree.DefaultTypeRegistry())
+	assert.Same(t, enc, jtree.DefaultEncodingRegistry())
+
+	var dest struct {
+		C     celsius `json:"c"`
+		Upper []byte  `json:"upper,upper"`
+		Hex   []byte  `json:"hex,hex"`
+	}
+	if assert.NoError(t, mustParse(t, `{"c": 0, "upper": "abc", "hex": "ff"}`).Decode(&dest)) {
+		assert.Equal(t, celsius(5), dest.C)
+		assert.Equal(t, []byte("ABC"), dest.Upper)
+		assert.Equal(t, []byte{0xff}, dest.Hex)
+	}
+	assert.Panics(t, func() { jtree.SetDefaultTypeRegistry(nil) })
+}
+
+func TestTryRegister(t *testing.T) {
+	reg := jtree.NewTypeRegistry()
+	assert.EqualError(t, reg.TryRegisterType(1), "jtree: function expected: int")
+	assert.EqualError(t, reg.TryRegisterType(func() {}), "jtree: invalid signature: func()")
+	assert.NoError(t, reg.TryRegisterType(func(jtree.Node, *jtree.Context) (celsius, error) { return 0, nil }))
+	assert.EqualError(t, reg.TryRegisterType(func(jtree.Node, *jtree.Context) (celsius, error) { return 0, nil }), "jtree: duplicate user type: jtree_test.celsius")
+	assert.EqualError(t, jtree.TryRegisterTypeFor(reg, func(jtree.Node, *jtree.Context) (*celsius, error) { return nil, nil }), "jtree: user type must not be a pointer: *jtree_test.celsius")
+
+	enc := jtree.NewEncodingRegistry()
+	assert.NoError(t, enc.TryRegisterEncoding("upper", upperEncoding{}))
+	assert.EqualError(t, enc.TryRegisterEncoding("upper", upperEncoding{}), "jtree: duplicate encoding: upper")
+	assert.EqualError(t, enc.TryRegisterEncoding("nil", nil), "jtree: nil encoding: nil")
+	assert.Error(t, jtree.TryRegisterEncoding("hex", jtree.Hex))
+}
@@ -0,0 +1,64 @@
+package jtree
+
+// Union returns the object containing fields of o followed by fields of other with keys missing in o
+func (o Object) Union(other Object) Object {
+	idx := o.Index()
+	out := make(Object, len(o), len(o)+len(other))
+	copy(out, o)
+	for _, f := range other {
+		if _, ok := idx.Get(f.Key); !ok {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Intersect returns the object containing fields of o with keys present in other
+func (o Object) Intersect(other Object) Object {
+	return o.filter(other, true)
+}
+
+// Subtract returns the object containing fields of o with keys missing in other
+func (o Object) Subtract(other Object) Object {
+	return o.filter(other, false)
+}
+
+func (o Object) filter(other Object, present bool) Object {
+	idx := other.Index()
+	out := make(Object, 0, len(o))
+	for _, f := range o {
+		if _, ok := idx.Get(f.Key); ok == present {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Concat returns the array containing elements of a followed by elements of others
+func (a Array) Concat(others ...Array) Array {
+	n := len(a)
+	for _, b := range others {
+		n += len(b)
+	}
+	out := make(Array, 0, n)
+	out = append(out, a...)
+	for _, b := range others {
+		out = append(out, b...)
+	}
+	return out
+}
+
+// Unique returns the array without duplicate elements keeping the first occurrence. Elements are compared using Equal
+func (a Array) Unique(op ...EqualOption) Array {
+	out := make(Array, 0, len(a))
+next:
+	for _, elem := range a {
+		for _, e := range out {
+			if Equal(e, elem, op...) {
+				continue next
+			}
+		}
+		out = append(out, elem)
+	}
+	return out
+}
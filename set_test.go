@@ -0,0 +1,24 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOperations(t *testing.T) {
+	a := mustParse(t, `{"x": 1, "y": 2, "z": 3}`).(jtree.Object)
+	b := mustParse(t, `{"y": 20, "w": 40}`).(jtree.Object)
+
+	assert.Equal(t, mustParse(t, `{"x": 1, "y": 2, "z": 3, "w": 40}`), a.Union(b))
+	assert.Equal(t, mustParse(t, `{"y": 2}`), a.Intersect(b))
+	assert.Equal(t, mustParse(t, `{"x": 1, "z": 3}`), a.Subtract(b))
+	assert.Equal(t, mustParse(t, `{"x": 1, "y": 2, "z": 3}`), a)
+
+	arr := mustParse(t, `[1, {"a": 1, "b": 2}, 1.0, "1"]`).(jtree.Array)
+	arr = arr.Concat(mustParse(t, `[{"b": 2, "a": 1}]`).(jtree.Array), mustParse(t, `["1", null]`).(jtree.Array))
+	assert.Equal(t, mustParse(t, `[1, {"a": 1, "b": 2}, 1.0, "1", {"b": 2, "a": 1}, "1", null]`), arr)
+	assert.Equal(t, mustParse(t, `[1, {"a": 1, "b": 2}, "1", null]`), arr.Unique())
+	assert.Equal(t, mustParse(t, `[1, {"a": 1, "b": 2}, "1", {"b": 2, "a": 1}, null]`), arr.Unique(jtree.OpOrderedKeys))
+}
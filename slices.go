@@ -0,0 +1,84 @@
+package jtree
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// primitiveSetter returns a function storing elements of the common primitive slice types directly, bypassing the
+// reflection based decoder. It returns false for nodes it can't handle so the caller can fall back to the generic path.
+// nil is returned if the destination or the decoding options don't allow the fast path
+func primitiveSetter(dst reflect.Value, opt *options) func(i int, n Node) bool {
+	if dst.Kind() != reflect.Slice || opt.elem != nil {
+		return nil
+	}
+	ctx := opt.ctx()
+	if len(ctx.hooks) != 0 {
+		return nil
+	}
+	if max := ctx.maxDecodeDepth(); max > 0 && opt.depth+1 > max {
+		return nil
+	}
+	if r := ctx.types(); r.constructor(dst.Type().Elem()) != nil || r.hasCoercions() {
+		return nil
+	}
+	switch s := dst.Interface().(type) {
+	case []string:
+		return func(i int, n Node) bool {
+			v, ok := n.(String)
+			if ok {
+				s[i] = string(v)
+			}
+			return ok
+		}
+	case []int:
+		return func(i int, n Node) bool {
+			num, ok := n.(*Num)
+			if !ok {
+				return false
+			}
+			v, ok := num.int64Value()
+			if !ok || v < math.MinInt || v > math.MaxInt {
+				return false
+			}
+			s[i] = int(v)
+			return true
+		}
+	case []int64:
+		return func(i int, n Node) bool {
+			num, ok := n.(*Num)
+			if !ok {
+				return false
+			}
+			v, ok := num.int64Value()
+			if ok {
+				s[i] = v
+			}
+			return ok
+		}
+	case []float64:
+		return func(i int, n Node) bool {
+			num, ok := n.(*Num)
+			if !ok {
+				return false
+			}
+			f := (*big.Float)(num)
+			v, _ := f.Float64()
+			if math.IsInf(v, 0) && !f.IsInf() {
+				return false
+			}
+			s[i] = v
+			return true
+		}
+	case []bool:
+		return func(i int, n Node) bool {
+			v, ok := n.(Bool)
+			if ok {
+				s[i] = bool(v)
+			}
+			return ok
+		}
+	}
+	return nil
+}
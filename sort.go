@@ -0,0 +1,77 @@
+package jtree
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Sort sorts the array in place using less function. The sort is stable
+func (a Array) Sort(less func(a, b Node) bool) {
+	sort.SliceStable(a, func(i, j int) bool { return less(a[i], a[j]) })
+}
+
+// SortByKey sorts the array of objects in place by the value located by the dot separated path, see Get.
+// Missing values and nulls go first followed by booleans, numbers and strings. Other values are considered equal.
+// The sort is stable
+func (a Array) SortByKey(path string) {
+	p := splitDotted(path)
+	keys := make([]Node, len(a))
+	for i, elem := range a {
+		keys[i] = get(elem, p)
+	}
+	sort.Stable(keySorter{a: a, keys: keys})
+}
+
+type keySorter struct {
+	a    Array
+	keys []Node
+}
+
+func (s keySorter) Len() int           { return len(s.a) }
+func (s keySorter) Less(i, j int) bool { return compareScalar(s.keys[i], s.keys[j]) < 0 }
+func (s keySorter) Swap(i, j int) {
+	s.a[i], s.a[j] = s.a[j], s.a[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func scalarRank(n Node) int {
+	switch n.(type) {
+	case nil, Null:
+		return 0
+	case Bool:
+		return 1
+	case *Num:
+		return 2
+	case String:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func compareScalar(a, b Node) int {
+	ra, rb := scalarRank(a), scalarRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch x := a.(type) {
+	case Bool:
+		y := b.(Bool)
+		if x == y {
+			return 0
+		} else if !x {
+			return -1
+		}
+		return 1
+	case *Num:
+		return (*big.Float)(x).Cmp((*big.Float)(b.(*Num)))
+	case String:
+		return strings.Compare(string(x), string(b.(String)))
+	default:
+		return 0
+	}
+}
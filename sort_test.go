@@ -0,0 +1,30 @@
+package jtree_test
+
+import (
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArraySort(t *testing.T) {
+	a := mustParse(t, `["b", "c", "a"]`).(jtree.Array)
+	a.Sort(func(x, y jtree.Node) bool { return x.(jtree.String) > y.(jtree.String) })
+	assert.Equal(t, mustParse(t, `["c", "b", "a"]`), a)
+
+	a = mustParse(t, `[
+		{"n": 1, "u": {"age": 30}},
+		{"n": 2, "u": {"age": "x"}},
+		{"n": 3, "u": {"age": 4}},
+		{"n": 4},
+		{"n": 5, "u": {"age": true}},
+		{"n": 6, "u": {"age": 4.0}},
+		{"n": 7, "u": {"age": null}}
+	]`).(jtree.Array)
+	a.SortByKey("u.age")
+	var order []int64
+	for _, r := range jtree.Get(a, "*.n").Array() {
+		order = append(order, r.Int())
+	}
+	assert.Equal(t, []int64{4, 7, 5, 3, 6, 1, 2}, order)
+}
@@ -0,0 +1,99 @@
+package jtree
+
+import "time"
+
+// ParseStats describes a single Parse call
+type ParseStats struct {
+	Bytes    int64         // bytes consumed from the input
+	Nodes    int64         // nodes created
+	MaxDepth int           // maximum nesting of containers, zero for scalars
+	Duration time.Duration // time spent
+}
+
+// OpParseStats sets the function called at the end of every Parse call including failed ones.
+// Decoder in the direct mode reports every Decode call
+func OpParseStats(fn func(ParseStats)) ParserOption { return func(o *parserOptions) { o.stats = fn } }
+
+type parseStart struct {
+	t     time.Time
+	bytes int64
+	nodes int64
+}
+
+func (p *Parser) startStats() parseStart {
+	p.maxDepth = 0
+	return parseStart{t: time.Now(), bytes: p.r.nbytes, nodes: p.nodes}
+}
+
+func (p *Parser) reportStats(s parseStart) {
+	p.opt.stats(ParseStats{
+		Bytes:    p.r.nbytes - s.bytes,
+		Nodes:    p.nodes - s.nodes,
+		MaxDepth: p.maxDepth,
+		Duration: time.Since(s.t),
+	})
+}
+
+func (p *Parser) enter() {
+	p.depth++
+	if p.depth > p.maxDepth {
+		p.maxDepth = p.depth
+	}
+}
+
+func (p *Parser) leave() { p.depth-- }
+
+// DecodeStats describes a single top level Decode call
+type DecodeStats struct {
+	Values   int64         // values decoded including members and elements
+	MaxDepth int           // maximum depth of decoded values, the top level value has the depth of zero
+	Duration time.Duration // time spent
+}
+
+// OpDecodeStats sets the function called at the end of every top level Decode call including failed ones.
+// Nested Decode calls made by custom decoders with OpCtx are accounted to the top level call.
+// The option is global for all Decode calls in chain
+func OpDecodeStats(fn func(DecodeStats)) Option {
+	return func(o *options) { o.mutableCtx().statsFn = fn }
+}
+
+// WithDecodeStats is the OpDecodeStats equivalent
+func WithDecodeStats(fn func(DecodeStats)) ContextOption { return func(c *Context) { c.statsFn = fn } }
+
+func (c *Context) statsOf() *decodeStats {
+	if c == nil {
+		return nil
+	}
+	return c.stats
+}
+
+// decodeStats holds counters of the top level Decode call
+type decodeStats struct {
+	DecodeStats
+	fn    func(DecodeStats)
+	start time.Time
+}
+
+// startStats attaches counters to the copy of the context if statistics are enabled and the call is the top level one.
+// The returned counters must be reported with end
+func (o *options) startStats() *decodeStats {
+	c := o.context
+	if c == nil || c.statsFn == nil || c.stats != nil {
+		return nil
+	}
+	s := &decodeStats{fn: c.statsFn, start: time.Now()}
+	o.mutableCtx().stats = s
+	return s
+}
+
+func (s *decodeStats) end() {
+	s.Duration = time.Since(s.start)
+	s.fn(s.DecodeStats)
+}
+
+func (s *decodeStats) visit(depth int) {
+	s.Values++
+	if depth > s.MaxDepth {
+		s.MaxDepth = depth
+	}
+}
@@ -0,0 +1,87 @@
+package jtree_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStats(t *testing.T) {
+	var stats []jtree.ParseStats
+	p := jtree.NewParser(strings.NewReader(`{"a": [1, {"b": 2}]} 3`), jtree.OpParseStats(func(s jtree.ParseStats) {
+		stats = append(stats, s)
+	}))
+	for i := 0; i < 2; i++ {
+		_, err := p.Parse()
+		assert.NoError(t, err)
+	}
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, int64(5), stats[0].Nodes)
+		assert.Equal(t, int64(20), stats[0].Bytes)
+		assert.Equal(t, 3, stats[0].MaxDepth)
+		assert.Equal(t, int64(1), stats[1].Nodes)
+		assert.Equal(t, 0, stats[1].MaxDepth)
+	}
+}
+
+func TestDecodeStats(t *testing.T) {
+	type item struct {
+		A []int `json:"a"`
+	}
+	var stats []jtree.DecodeStats
+	op := jtree.OpDecodeStats(func(s jtree.DecodeStats) { stats = append(stats, s) })
+	src := `{"a": [1, 2]}`
+
+	var dest item
+	assert.NoError(t, mustParse(t, src).Decode(&dest, op))
+	dec := jtree.NewDecoder(strings.NewReader(src))
+	dec.Direct()
+	assert.NoError(t, dec.DecodeContext(context.Background(), &dest, op))
+	if assert.Len(t, stats, 2) {
+		for _, s := range stats {
+			assert.Equal(t, jtree.DecodeStats{Values: 4, MaxDepth: 2, Duration: s.Duration}, s)
+		}
+	}
+}
+
+func TestDecodeStatsContext(t *testing.T) {
+	var (
+		mtx   sync.Mutex
+		stats []jtree.DecodeStats
+	)
+	fn := func(s jtree.DecodeStats) {
+		mtx.Lock()
+		stats = append(stats, s)
+		mtx.Unlock()
+	}
+	src := mustParse(t, `["a", "b"]`)
+
+	// nested calls made by custom decoders are accounted to the top level call
+	for _, ctx := range []*jtree.Context{
+		jtree.NewContext(jtree.WithDecodeStats(fn)),
+		jtree.NewContext(jtree.WithOptions(jtree.OpDecodeStats(fn))),
+	} {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var dest []tenantRecord
+				assert.NoError(t, src.Decode(&dest, jtree.OpCtx(ctx)))
+			}()
+		}
+		wg.Wait()
+	}
+	var dest []tenantRecord
+	assert.NoError(t, src.Decode(&dest, jtree.OpCtx(jtree.NewContext()), jtree.OpDecodeStats(fn)))
+
+	if assert.Len(t, stats, 9) {
+		for _, s := range stats {
+			assert.Equal(t, jtree.DecodeStats{Values: 5, MaxDepth: 1, Duration: s.Duration}, s)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package jtree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Direct makes Decode to write values straight into the destination without building the intermediate tree.
+// Structs, slices and maps with string keys are filled directly from the input while other values including Node
+// and interface typed members, types with custom decoders and scalars are parsed into nodes and decoded as usual.
+// Numbers stored into integer and floating point destinations don't allocate nodes.
+// Decoding options requiring the whole tree like OpCollectErrors, OpMergeExisting and decode hooks disable the mode.
+// Must be called before the first Decode call
+func (dec *Decoder) Direct() {
+	dec.direct = true
+}
+
+// canDecodeDirect returns true if no parser option requires the tree
+func (p *Parser) canDecodeDirect() bool {
+	return !p.opt.recover && p.opt.skip == nil && p.opt.raw == nil && !p.opt.comments && p.opt.tee == nil && p.opt.shallow == 0
+}
+
+// decodeDirect parses the next value and decodes it into v without building the tree
+func (p *Parser) decodeDirect(v interface{}, op []Option) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr {
+		return fmt.Errorf("jtree: pointer expected: %v", val.Type())
+	}
+	if val.IsNil() {
+		return errors.New("jtree: nil pointer")
+	}
+	if p.opt.stats != nil {
+		defer p.reportStats(p.startStats())
+	}
+	opt := new(options).apply(op)
+	if s := opt.startStats(); s != nil {
+		defer s.end()
+	}
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if err := p.directValue(tok, val.Elem(), opt); err != nil {
+		return err
+	}
+	if p.opt.progress != nil {
+		return p.report()
+	}
+	return nil
+}
+
+// directType returns true if the value of type t can be decoded directly with options opt
+func directType(t reflect.Type, opt *options) bool {
+	ctx := opt.ctx()
+	if len(ctx.hooks) != 0 || ctx.collect || ctx.merge || ctx.proto {
+		return false
+	}
+	if opt.str || opt.enc != nil || opt.elem != nil || opt.key != nil || opt.notNull || len(opt.computed) != 0 {
+		return false
+	}
+	if t == rawType || t == jsonRawMessageType {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	if pt.Implements(ctxDecoderType) || pt.Implements(decoderType) || pt.Implements(jsonUnmarshalerType) || pt.Implements(optionalDecoderType) {
+		return false
+	}
+	r := ctx.types()
+	return r.constructor(t) == nil && !r.hasCoercions()
+}
+
+func (p *Parser) directValue(tok token, out reflect.Value, opt *options) error {
+	ctx := opt.ctx()
+	if ctx.cancel != nil {
+		if err := ctx.cancel.check(); err != nil {
+			return err
+		}
+	}
+	if max := ctx.maxDecodeDepth(); max > 0 && opt.depth > max {
+		return fmt.Errorf("jtree: maximum decode depth of %d exceeded", max)
+	}
+	if tok.delim('{') || tok.delim('[') {
+		t := out.Type()
+		if t.Kind() == reflect.Ptr && directType(t, opt) && directType(t.Elem(), opt) {
+			if !out.IsNil() {
+				return p.directValue(tok, out.Elem(), opt)
+			}
+			var ptr pendingPtr
+			err := p.directValue(tok, ptr.alloc(out), opt)
+			ptr.commit(err, opt)
+			return err
+		}
+		if directType(t, opt) {
+			switch {
+			case tok.ch == '{' && t.Kind() == reflect.Struct && planFor(t).direct:
+				return p.directStruct(out, opt)
+			case tok.ch == '{' && t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+				!reflect.PtrTo(t.Key()).Implements(textUnmarshalerType):
+				return p.directMap(out, opt)
+			case tok.ch == '[' && t.Kind() == reflect.Slice:
+				return p.directSlice(out, opt)
+			}
+		}
+	}
+	if tok.kind == tokNum && p.directNumber(tok, out, opt) {
+		return nil
+	}
+	// build the subtree
+	n, err := p.parse(tok)
+	if err != nil {
+		return err
+	}
+	return n.Decode(out.Addr().Interface(), opCopy(opt))
+}
+
+// directNumber stores the number literal into the numeric destination without allocating the node. Integers
+// fitting into int64 are converted by strconv, other values go through the scratch big.Float to match the
+// node conversion. It returns false to let the generic path handle the value and report errors
+func (p *Parser) directNumber(tok token, out reflect.Value, opt *options) bool {
+	t := out.Type()
+	k := t.Kind()
+	if !(k >= reflect.Int && k <= reflect.Float64) || k == reflect.Uintptr || t == durationType || !directType(t, opt) {
+		return false
+	}
+	s := tok.str
+	if p.opt.strictNumbers && !validNumber(s) {
+		return false
+	}
+	switch {
+	case k >= reflect.Int && k <= reflect.Int64:
+		i, ok := smallInt(s)
+		if !ok || out.OverflowInt(i) {
+			return false
+		}
+		out.SetInt(i)
+
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		i, ok := smallInt(s)
+		if !ok || i < 0 || out.OverflowUint(uint64(i)) {
+			return false
+		}
+		out.SetUint(uint64(i))
+
+	default:
+		var f float64
+		if i, ok := smallInt(s); ok {
+			// single rounding of the exact integer like SetInt64 followed by Float64
+			f = float64(i)
+		} else {
+			if _, _, err := p.num.Parse(s, 10); err != nil || p.num.IsInf() {
+				return false
+			}
+			f, _ = p.num.Float64()
+		}
+		if math.IsInf(f, 0) || out.OverflowFloat(f) {
+			return false
+		}
+		out.SetFloat(f)
+	}
+	p.nodes++
+	if s := opt.context.statsOf(); s != nil {
+		s.visit(opt.depth)
+	}
+	return true
+}
+
+func (p *Parser) directStruct(out reflect.Value, opt *options) error {
+	p.nodes++
+	p.enter()
+	defer p.leave()
+	if s := opt.context.statsOf(); s != nil {
+		s.visit(opt.depth)
+	}
+	plan := planFor(out.Type())
+	seen := make(map[string]bool, len(plan.list))
+	var child options
+	err := p.members(func(key string, tok token) error {
+		field, ok := plan.fields[key]
+		if !ok {
+			if opt.ctx().noUnknown {
+				return fmt.Errorf("jtree: undefined field '%s': %v", key, out.Type())
+			}
+			return p.skip(tok)
+		}
+		seen[key] = true
+		applyFieldTags(opt.child(&child), field.tags, opt)
+		var ptr pendingPtr
+		err := p.directValue(tok, fieldValue(out, field.StructField, &ptr), &child)
+		ptr.commit(err, opt)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, f := range plan.list {
+		if !seen[f.Name] && f.required(opt.ctx().requireFields) {
+			missing = append(missing, f.Name)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("jtree: missing required fields %s: %v", strings.Join(missing, ", "), out.Type())
+	}
+	return nil
+}
+
+func (p *Parser) directMap(out reflect.Value, opt *options) error {
+	p.nodes++
+	p.enter()
+	defer p.leave()
+	if s := opt.context.statsOf(); s != nil {
+		s.visit(opt.depth)
+	}
+	t := out.Type()
+	dst := reflect.MakeMap(t)
+	child := opt.child(new(options))
+	err := p.members(func(key string, tok token) error {
+		elem := reflect.New(t.Elem()).Elem()
+		if err := p.directValue(tok, elem, child); err != nil {
+			return err
+		}
+		dst.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	out.Set(dst)
+	return nil
+}
+
+func (p *Parser) directSlice(out reflect.Value, opt *options) error {
+	p.nodes++
+	p.enter()
+	defer p.leave()
+	if s := opt.context.statsOf(); s != nil {
+		s.visit(opt.depth)
+	}
+	// grown in place, reflect.Append allocates on every call
+	dst := reflect.New(out.Type()).Elem()
+	dst.Set(reflect.MakeSlice(out.Type(), 0, 0))
+	child := opt.child(new(options))
+	err := p.elements(func(tok token) error {
+		n := dst.Len()
+		dst.Grow(1)
+		dst.SetLen(n + 1)
+		elem := dst.Index(n)
+		elem.SetZero()
+		return p.directValue(tok, elem, child)
+	})
+	if err != nil {
+		return err
+	}
+	out.Set(dst)
+	return nil
+}
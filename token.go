@@ -0,0 +1,128 @@
+package jtree
+
+import (
+	"fmt"
+	"io"
+)
+
+// TokenKind is the lexical token kind
+type TokenKind int
+
+const (
+	// TokenDelim is one of the delimiters: {}[],:
+	TokenDelim TokenKind = iota
+	// TokenString is the unescaped string literal
+	TokenString
+	// TokenNumber is the number literal
+	TokenNumber
+	// TokenKeyword is the keyword i.e. true, false or null
+	TokenKeyword
+	// TokenError is the lexical error. Value contains the error message
+	TokenError
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenDelim:
+		return "delimiter"
+	case TokenString:
+		return "string"
+	case TokenNumber:
+		return "number"
+	case TokenKeyword:
+		return "keyword"
+	case TokenError:
+		return "error"
+	default:
+		return fmt.Sprintf("TokenKind(%d)", int(k))
+	}
+}
+
+// Token is the lexical token passed to the trace function
+type Token struct {
+	Kind   TokenKind
+	Value  string
+	Offset int64
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("%v %q at position %d", t.Kind, t.Value, t.Offset)
+}
+
+func exportToken(tok token) Token {
+	switch tok.kind {
+	case tokDelim:
+		return Token{Kind: TokenDelim, Value: string(tok.ch), Offset: tok.p}
+	case tokNum:
+		return Token{Kind: TokenNumber, Value: tok.str, Offset: tok.p}
+	case tokRes:
+		return Token{Kind: TokenKeyword, Value: tok.str, Offset: tok.p}
+	case tokString:
+		return Token{Kind: TokenString, Value: tok.str, Offset: tok.p}
+	default:
+		panic("unexpected token")
+	}
+}
+
+func importToken(t Token) (token, error) {
+	switch t.Kind {
+	case TokenDelim:
+		if len(t.Value) != 1 {
+			return token{}, fmt.Errorf("jtree: invalid delimiter token: %v", t)
+		}
+		return token{kind: tokDelim, ch: rune(t.Value[0]), p: t.Offset}, nil
+	case TokenString:
+		return token{kind: tokString, str: t.Value, p: t.Offset}, nil
+	case TokenNumber:
+		return token{kind: tokNum, str: t.Value, p: t.Offset}, nil
+	case TokenKeyword:
+		return token{kind: tokRes, str: t.Value, p: t.Offset}, nil
+	case TokenError:
+		return token{}, &SyntaxError{Offset: t.Offset, msg: t.Value}
+	default:
+		return token{}, fmt.Errorf("jtree: invalid token: %v", t)
+	}
+}
+
+// OpTrace sets the function called for every token read by the lexer including lexical errors.
+// It can be used to record the token stream or to attach a step-through debugger
+func OpTrace(fn func(tok Token)) ParserOption { return func(o *parserOptions) { o.trace = fn } }
+
+// TokenRecorder collects the token stream. Pass its Record method to OpTrace
+type TokenRecorder struct {
+	Tokens []Token
+}
+
+// Record appends the token to the recording
+func (r *TokenRecorder) Record(tok Token) {
+	r.Tokens = append(r.Tokens, tok)
+}
+
+// NewReplayParser returns new Parser reading the previously recorded token stream instead of the source text.
+// Raw nodes and values passed to OpTee contain the text reconstructed from tokens
+func NewReplayParser(tokens []Token, op ...ParserOption) *Parser {
+	return newParser(&reader{unr: -1, replay: tokens, replaying: true, opt: &parserOptions{}}, op)
+}
+
+func (r *reader) replayToken() (token, error) {
+	if len(r.replay) == 0 {
+		r.eof = true
+		return token{}, io.EOF
+	}
+	t := r.replay[0]
+	r.replay = r.replay[1:]
+	tok, err := importToken(t)
+	if err != nil {
+		return token{}, err
+	}
+	// reconstruct the source text
+	if t.Kind == TokenString && (r.capturing > 0 || r.opt.raw != nil) {
+		r.quoted = appendString(nil, t.Value)
+		if r.capturing > 0 {
+			r.buf = append(r.buf, r.quoted...)
+		}
+	} else if r.capturing > 0 {
+		r.buf = append(r.buf, t.Value...)
+	}
+	return tok, nil
+}
@@ -0,0 +1,57 @@
+package jtree_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenReplay(t *testing.T) {
+	src := `{"a": [1, "x\ty"], "b": null}`
+	var rec jtree.TokenRecorder
+	expect, err := jtree.NewParser(strings.NewReader(src), jtree.OpTrace(rec.Record)).Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, jtree.Token{Kind: jtree.TokenString, Value: "x\ty", Offset: 10}, rec.Tokens[6])
+
+	node, err := jtree.NewReplayParser(rec.Tokens).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, expect, node)
+	}
+
+	node, err = jtree.NewReplayParser(rec.Tokens, jtree.OpShallow(1)).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Object{
+			{"a", jtree.Raw(`[1,"x\ty"]`)},
+			{"b", jtree.Null{}},
+		}, node)
+	}
+
+	// reconstructed text is valid JSON
+	rec = jtree.TokenRecorder{}
+	src = `{"a": ["\u0007\u000b\u007f\u00e9"], "b": "\u0007"}`
+	expect, err = jtree.NewParser(strings.NewReader(src), jtree.OpTrace(rec.Record)).Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	node, err = jtree.NewReplayParser(rec.Tokens, jtree.OpShallow(1), jtree.OpRawPaths("/b")).Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, jtree.Object{
+			{"a", jtree.Raw("[\"\\u0007\\u000b\x7fé\"]")},
+			{"b", jtree.Raw(`"\u0007"`)},
+		}, node)
+		var a []string
+		if assert.NoError(t, jtree.Unmarshal(node.(jtree.Object)[0].Value.(jtree.Raw), &a)) {
+			assert.Equal(t, []string{"\a\v\x7fé"}, a)
+		}
+	}
+
+	rec = jtree.TokenRecorder{}
+	_, err = jtree.NewParser(strings.NewReader(`[1, @]`), jtree.OpTrace(rec.Record)).Parse()
+	assert.EqualError(t, err, "jtree: unexpected character '@' at position 4")
+	_, err = jtree.NewReplayParser(rec.Tokens).Parse()
+	assert.EqualError(t, err, "jtree: unexpected character '@' at position 4")
+}
@@ -0,0 +1,44 @@
+package jtree
+
+import "strconv"
+
+// TransformFunc is called for every node of the tree with the path consisting of object keys and decimal array indices.
+// Containers are visited after their children, so the node passed to the function already contains transformed children.
+// The returned node replaces the visited one, nil removes it from the parent container. path must not be retained
+type TransformFunc func(path []string, n Node) (Node, error)
+
+// Transform returns a new tree produced by applying fn to every node of n. The original tree is not modified but the
+// result may share unchanged leaf nodes with it. nil is returned if fn removes the root node
+func Transform(n Node, fn TransformFunc) (Node, error) {
+	return transform(n, make([]string, 0), fn)
+}
+
+func transform(n Node, path []string, fn TransformFunc) (Node, error) {
+	switch node := n.(type) {
+	case Object:
+		out := make(Object, 0, len(node))
+		for _, f := range node {
+			v, err := transform(f.Value, append(path, f.Key), fn)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				out = append(out, &Field{Key: f.Key, Value: v})
+			}
+		}
+		n = out
+	case Array:
+		out := make(Array, 0, len(node))
+		for i, elem := range node {
+			v, err := transform(elem, append(path, strconv.FormatInt(int64(i), 10)), fn)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				out = append(out, v)
+			}
+		}
+		n = out
+	}
+	return fn(path, n)
+}
@@ -0,0 +1,49 @@
+package jtree_test
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ecadlabs/jtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransform(t *testing.T) {
+	src := mustParse(t, `{"legacy_id": "42", "items": [{"n": "1"}, {"n": "x"}, null], "drop": true}`)
+	orig := src.Clone()
+
+	var paths []string
+	out, err := jtree.Transform(src, func(path []string, n jtree.Node) (jtree.Node, error) {
+		paths = append(paths, strings.Join(path, "/"))
+		switch node := n.(type) {
+		case jtree.Object:
+			node.Rename("legacy_id", "id")
+			return node, nil
+		case jtree.String:
+			if v, ok := new(big.Float).SetString(string(node)); ok {
+				return (*jtree.Num)(v), nil
+			}
+		case jtree.Null:
+			return nil, nil
+		case jtree.Bool:
+			if len(path) != 0 && path[len(path)-1] == "drop" {
+				return nil, nil
+			}
+		}
+		return n, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"legacy_id", "items/0/n", "items/0", "items/1/n", "items/1", "items/2", "items", "drop", ""}, paths)
+	assert.True(t, jtree.Equal(mustParse(t, `{"id": 42, "items": [{"n": 1}, {"n": "x"}]}`), out))
+	assert.Equal(t, orig, src)
+
+	out, err = jtree.Transform(src, func(path []string, n jtree.Node) (jtree.Node, error) { return nil, nil })
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	e := errors.New("error")
+	_, err = jtree.Transform(src, func(path []string, n jtree.Node) (jtree.Node, error) { return nil, e })
+	assert.Equal(t, e, err)
+}
@@ -0,0 +1,84 @@
+package jtree
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// decodeUnion decodes elem into the interface typed dest using the variant named by the sibling member disc of o
+func decodeUnion(dest reflect.Value, o Object, disc string, elem Node, opt *options) error {
+	t := dest.Type()
+	if t.Kind() != reflect.Interface {
+		return fmt.Errorf("jtree: union field must be an interface: %v", t)
+	}
+	if _, ok := elem.(Null); ok {
+		dest.Set(reflect.Zero(t))
+		return nil
+	}
+	name, ok := o.FieldByName(disc).(String)
+	if !ok {
+		return fmt.Errorf("jtree: string discriminator '%s' expected: %v", disc, t)
+	}
+	vt := opt.ctx().types().variant(t, string(name))
+	if vt == nil {
+		return fmt.Errorf("jtree: unknown variant '%s': %v", name, t)
+	}
+	var v reflect.Value
+	if vt.Kind() == reflect.Ptr {
+		v = reflect.New(vt.Elem())
+		if err := decodeChild(elem, v.Elem(), opt); err != nil {
+			return err
+		}
+	} else {
+		v = reflect.New(vt).Elem()
+		if err := decodeChild(elem, v, opt); err != nil {
+			return err
+		}
+	}
+	dest.Set(v)
+	return nil
+}
+
+// RegisterUnion registers the constructor of the interface type I selecting the variant by the string member field
+// of the object. Variant functions return new prototype values which are decoded from the whole object.
+// nil r means the global registry
+func RegisterUnion[I any](r *TypeRegistry, field string, variants map[string]func() I) {
+	m := make(map[string]func() I, len(variants))
+	for k, v := range variants {
+		if v == nil {
+			panic(fmt.Sprintf("jtree: nil variant %q constructor: %v", k, reflect.TypeOf((*I)(nil)).Elem()))
+		}
+		m[k] = v
+	}
+	RegisterTypeFor(r, func(n Node, ctx *Context) (I, error) {
+		var zero I
+		obj, ok := n.(Object)
+		if !ok {
+			return zero, errors.New("jtree: object expected")
+		}
+		name, ok := obj.FieldByName(field).(String)
+		if !ok {
+			return zero, fmt.Errorf("jtree: string discriminator '%s' expected: %v", field, reflect.TypeOf(&zero).Elem())
+		}
+		fn, ok := m[string(name)]
+		if !ok {
+			return zero, fmt.Errorf("jtree: unknown variant '%s': %v", name, reflect.TypeOf(&zero).Elem())
+		}
+		dest := fn()
+		v := reflect.ValueOf(dest)
+		if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+			return zero, fmt.Errorf("jtree: variant '%s' constructor returned nil: %v", name, reflect.TypeOf(&zero).Elem())
+		}
+		if v.Kind() == reflect.Ptr {
+			return dest, n.Decode(dest, OpCtx(ctx))
+		}
+		// decode into the copy of the value prototype
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		if err := n.Decode(p.Interface(), OpCtx(ctx)); err != nil {
+			return zero, err
+		}
+		return p.Elem().Interface().(I), nil
+	})
+}
@@ -0,0 +1,7 @@
+//go:build !jtree_unsafe
+
+package jtree
+
+func bytesToString(b []byte) string { return string(b) }
+
+func stringToBytes(s string) []byte { return []byte(s) }
@@ -0,0 +1,24 @@
+//go:build jtree_unsafe
+
+package jtree
+
+import "unsafe"
+
+// Building with the jtree_unsafe tag enables zero-copy conversions between strings and byte slices in the lexer and
+// in the decoding of string encoded values. Encoding.Decode and encoding.TextUnmarshaler implementations receive
+// the memory of the immutable string node then and must neither modify nor return it. Token values passed to the
+// trace function are not affected
+
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(&b[0], len(b))
+}
+
+func stringToBytes(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
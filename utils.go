@@ -1,8 +1,10 @@
 package jtree
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type StructField struct {
@@ -26,7 +28,8 @@ func collectFields(t reflect.Type, index []int, ptr []reflect.Type, out map[stri
 		if name == "-" {
 			continue
 		}
-		if name == "" && f.Anonymous && (f.Type.Kind() == reflect.Struct || f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct) {
+		isStruct := f.Type.Kind() == reflect.Struct || f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct
+		if isStruct && (name == "" && f.Anonymous || f.IsExported() && hasTagOption(opt, "squash")) {
 			// dive
 			ft := f.Type
 			if ft.Kind() == reflect.Ptr {
@@ -51,12 +54,6 @@ func collectFields(t reflect.Type, index []int, ptr []reflect.Type, out map[stri
 			if name == "" {
 				name = f.Name
 			}
-			if prev, ok := out[name]; ok {
-				// we use simplified duplicated fields visibility rule here: shallowest and topmost wins
-				if len(prev.Index) <= len(f.Index) {
-					continue
-				}
-			}
 			tmp := f
 			tmp.Index = mkIndex(index, f.Index)
 			field := &StructField{
@@ -64,6 +61,17 @@ func collectFields(t reflect.Type, index []int, ptr []reflect.Type, out map[stri
 				Options:     opt,
 				Name:        name,
 			}
+			if field.inline() {
+				// catch-all field doesn't match any key
+				list = append(list, field)
+				continue
+			}
+			if prev, ok := out[name]; ok {
+				// we use simplified duplicated fields visibility rule here: shallowest and topmost wins
+				if len(prev.Index) <= len(f.Index) {
+					continue
+				}
+			}
 			out[name] = field
 			list = append(list, field)
 		}
@@ -71,48 +79,137 @@ func collectFields(t reflect.Type, index []int, ptr []reflect.Type, out map[stri
 	return
 }
 
+func (f *StructField) hasOption(opt string) bool {
+	return hasTagOption(f.Options, opt)
+}
+
+// inline returns true for the catch-all map field receiving unmatched object members
+func (f *StructField) inline() bool {
+	return f.hasOption("inline") && f.Type.Kind() == reflect.Map && f.Type.Key().Kind() == reflect.String
+}
+
+// option returns the value of the key=value option
+func (f *StructField) option(key string) (string, bool) {
+	for _, o := range f.Options {
+		if len(o) > len(key) && o[len(key)] == '=' && o[:len(key)] == key {
+			return o[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// required returns true if the field is tagged as required or all fields are required and the field is not optional
+func (f *StructField) required(all bool) bool {
+	if f.hasOption("required") {
+		return true
+	}
+	return all && !f.inline() && !f.hasOption("omitempty") && !reflect.PtrTo(f.Type).Implements(optionalDecoderType)
+}
+
 func VisibleFields(t reflect.Type) []*StructField {
 	fields := make(map[string]*StructField)
 	return collectFields(t, nil, nil, fields)
 }
 
+func hasTagOption(opt []string, o string) bool {
+	for _, s := range opt {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
 func parseTag(tag string) (name string, opt []string) {
 	s := strings.Split(tag, ",")
 	return s[0], s[1:]
 }
 
-func parseFieldOptions(tags []string, opt *options) []Option {
-	out := make([]Option, 0, len(tags))
-	elemOp := make([]Option, 0, len(tags))
+// tagKeywords are field tag options which are never resolved as encoding names
+var tagKeywords = map[string]bool{
+	"string":    true,
+	"notnull":   true,
+	"omitempty": true,
+	"required":  true,
+	"inline":    true,
+	"squash":    true,
+}
+
+// isTagKeyword returns true for the field tag keyword or the key=value tag option
+func isTagKeyword(s string) bool {
+	return tagKeywords[s] || strings.IndexByte(s, '=') >= 0
+}
+
+// fieldTag is the pre-parsed field tag option. Encoding names are resolved against the context registry
+// and cached until the registry changes
+type fieldTag struct {
+	op   Option
+	enc  *encodingCache
+	elem bool
+}
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"μs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+func parseFieldTags(tags []string) ([]fieldTag, error) {
+	out := make([]fieldTag, 0, len(tags))
 	for _, s := range tags {
 		if len(s) == 0 {
 			continue
 		}
-		elem := false
+		var t fieldTag
 		if s[0] == '[' {
 			if s[len(s)-1] != ']' {
 				continue
 			}
 			s = s[1 : len(s)-1]
-			elem = true
+			t.elem = true
 		}
-		var o Option
 		if s == "string" {
-			o = OpString
-		} else if enc := opt.ctx().encodings().get(s); enc != nil {
-			o = OpEncoding(enc)
-		} else {
+			t.op = OpString
+		} else if s == "notnull" {
+			t.op = opNotNull
+		} else if strings.HasPrefix(s, "unit=") {
+			unit, ok := durationUnits[s[len("unit="):]]
+			if !ok {
+				return nil, fmt.Errorf("invalid duration unit '%s'", s[len("unit="):])
+			}
+			t.op = OpDurationUnit(unit)
+		} else if isTagKeyword(s) {
+			// handled by the plan
 			continue
-		}
-		if elem {
-			elemOp = append(elemOp, o)
-			elem = false
 		} else {
-			out = append(out, o)
+			t.enc = &encodingCache{name: s}
 		}
+		out = append(out, t)
 	}
-	if len(elemOp) != 0 {
-		out = append(out, OpElem(elemOp...))
+	return out, nil
+}
+
+// applyFieldTags sets options of the field tagged with tags. Encoding names are resolved using the context of opt
+func applyFieldTags(dst *options, tags []fieldTag, opt *options) *options {
+	for i := range tags {
+		t := &tags[i]
+		o := dst
+		if t.elem {
+			if dst.elem == nil {
+				dst.elem = new(options)
+			}
+			o = dst.elem
+		}
+		if t.op != nil {
+			t.op(o)
+		} else if enc := t.enc.get(opt.ctx().encodings()); enc != nil {
+			o.enc = enc
+		}
 	}
-	return out
+	return dst
 }
@@ -0,0 +1,31 @@
+package jtree
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type validatingEncoding func(src []byte) error
+
+func (v validatingEncoding) Encode(src []byte) []byte { return src }
+
+func (v validatingEncoding) Decode(src []byte) ([]byte, error) {
+	if err := v(src); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), src...), nil
+}
+
+// ValidatingEncoding returns the encoding which doesn't transform the data but rejects it if fn returns an error.
+// Registered under a name it can be used with string fields to check identifier formats like `json:"id,uuid"`
+func ValidatingEncoding(fn func(src []byte) error) Encoding { return validatingEncoding(fn) }
+
+// PatternEncoding returns the validating encoding accepting data matching re
+func PatternEncoding(re *regexp.Regexp) Encoding {
+	return validatingEncoding(func(src []byte) error {
+		if !re.Match(src) {
+			return fmt.Errorf("%q doesn't match %v", src, re)
+		}
+		return nil
+	})
+}